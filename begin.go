@@ -0,0 +1,278 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrTransactionCompleted        = errors.New("transaction has already been completed")
+	ErrSavePointWithoutTransaction = errors.New("not in transaction, can't use savepoint")
+)
+
+type TxStatus int8
+
+const (
+	TxStatusActive     TxStatus = iota // 事务进行中
+	TxStatusCommitted                  // 事务已提交
+	TxStatusRolledBack                 // 事务已回滚
+)
+
+func (s TxStatus) String() string {
+	switch s {
+	case TxStatusActive:
+		return "ACTIVE"
+	case TxStatusCommitted:
+		return "COMMITTED"
+	case TxStatusRolledBack:
+		return "ROLLED_BACK"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// TxHandle complete a call started by Begin, it's not safe for concurrent use
+type TxHandle interface {
+	// Commit complete the call successfully: the transaction begun by the call commits, the savepoint created by a
+	// PropagationNested call is released, and a call participating in an existing transaction leaves it to the outer one
+	Commit() error
+	// Rollback complete the call as failed: the transaction begun by the call rolls back, the savepoint created by a
+	// PropagationNested call rolls back, and a call participating in an existing transaction marks it rollback only
+	// unless WithGlobalRollbackOnParticipationFailure(false) is set
+	Rollback() error
+	// SavePoint create a savepoint named name in the transaction of the call
+	SavePoint(name string) error
+	// RollbackTo roll back the transaction of the call to the savepoint named name
+	RollbackTo(name string) error
+	// Status return whether the call is active, committed or rolled back
+	Status() TxStatus
+}
+
+// Begin start a call with the same propagation semantics as Transaction, but return the context of the call and a
+// TxHandle completing it instead of running a callback. The returned context is bound to the transaction of the call
+// so that GetDB and nested calls use it until the call is completed. WithRetry is ignored.
+func (m *transactionManager) Begin(ctx context.Context, opts ...TransactionOption) (context.Context, TxHandle, error) {
+	options := newTransactionOptions(opts)
+	if m.inSavePointScope(ctx, options.propagation) {
+		options.propagation = PropagationNested
+	}
+	txCtx, inTransaction := m.fromContext(ctx)
+	inTransaction = inTransaction && txCtx.InTransaction()
+
+	switch options.propagation {
+	case PropagationRequired, PropagationMandatory:
+		if inTransaction {
+			if err := m.checkExistingTransaction(txCtx, options); err != nil {
+				return ctx, nil, err
+			}
+			return m.beginParticipation(ctx, txCtx, options)
+		} else if options.propagation == PropagationMandatory {
+			return ctx, nil, ErrMandatoryPropWithoutTransaction
+		}
+		return m.beginRoot(ctx, options)
+	case PropagationSupports:
+		if inTransaction {
+			return m.beginParticipation(ctx, txCtx, options)
+		}
+		return ctx, &txHandle{m: m, options: options}, nil
+	case PropagationRequiresNew:
+		return m.beginRoot(ctx, options)
+	case PropagationNotSupported:
+		return suspend(ctx, m.key), &txHandle{m: m, options: options}, nil
+	case PropagationNested:
+		if !inTransaction {
+			return m.beginRoot(ctx, options)
+		} else if txCtx.TxDB().DisableNestedTransaction {
+			return m.beginParticipation(ctx, txCtx, options)
+		}
+		return m.beginSavePoint(ctx, txCtx, options)
+	case PropagationNever:
+		if inTransaction {
+			return ctx, nil, ErrNeverPropInTransaction
+		}
+		return ctx, &txHandle{m: m, options: options}, nil
+	default:
+		panic("not supported propagation")
+	}
+}
+
+func (m *transactionManager) beginRoot(ctx context.Context, options *transactionOptions) (context.Context, TxHandle, error) {
+	name := callName(ctx, options)
+	txCtx, cancel, err := m.beginTransaction(ctx, name, options)
+	if err != nil {
+		return ctx, nil, err
+	}
+	h := &txHandle{
+		m:       m,
+		options: options,
+		ctx:     ctx,
+		txCtx:   txCtx,
+		cancel:  cancel,
+		begun:   true,
+		start:   time.Now(),
+	}
+	if m.watchdog != nil {
+		id := m.watchdog.track(name, m.key.datasource)
+		h.untrack = func() {
+			m.watchdog.untrack(id)
+		}
+	}
+	return txCtx, h, nil
+}
+
+func (m *transactionManager) beginParticipation(ctx context.Context, txCtx *transactionContext, options *transactionOptions) (context.Context, TxHandle, error) {
+	session, cancel := m.newSession(ctx, txCtx, "", options)
+	return session, &txHandle{m: m, options: options, ctx: ctx, txCtx: session, cancel: cancel}, nil
+}
+
+func (m *transactionManager) beginSavePoint(ctx context.Context, txCtx *transactionContext, options *transactionOptions) (context.Context, TxHandle, error) {
+	root := txCtx.Root()
+	mark := root.synchronizationMark()
+	rollbackOnly, rollbackCause := root.rollbackState()
+	savepoint := txCtx.nextSavePoint()
+	if err := txCtx.TxDB().SavePoint(savepoint).Error; err != nil {
+		return ctx, nil, err
+	}
+	session, cancel := m.newSession(ctx, txCtx, savepoint, options)
+	return session, &txHandle{
+		m:             m,
+		options:       options,
+		ctx:           ctx,
+		txCtx:         session,
+		cancel:        cancel,
+		savepoint:     savepoint,
+		mark:          mark,
+		rollbackOnly:  rollbackOnly,
+		rollbackCause: rollbackCause,
+	}, nil
+}
+
+type txHandle struct {
+	m       *transactionManager
+	options *transactionOptions
+	// ctx is the context Begin is called with
+	ctx context.Context
+	// txCtx is the transaction or the session of the call, nil if the call doesn't run in transaction
+	txCtx  *transactionContext
+	cancel context.CancelFunc
+	status TxStatus
+
+	// begun report whether the call began txCtx
+	begun   bool
+	start   time.Time
+	untrack func()
+
+	// savepoint is created by a PropagationNested call, the other fields are the state of the root transaction
+	// before it
+	savepoint     string
+	mark          int
+	rollbackOnly  bool
+	rollbackCause error
+}
+
+func (h *txHandle) Commit() error {
+	if h.status != TxStatusActive {
+		return ErrTransactionCompleted
+	}
+	defer h.complete()
+	switch {
+	case h.begun:
+		// the outcome is recorded even if a synchronization panics after the commit
+		defer func() {
+			if h.txCtx.committed {
+				h.status = TxStatusCommitted
+				h.m.observeCompletion(h.info(), time.Since(h.start), false, true)
+				if !h.options.readOnly {
+					h.m.stick(h.ctx)
+				}
+			}
+		}()
+		if err := h.txCtx.Commit(); err != nil {
+			h.rollback()
+			return timeoutError(h.txCtx.ctx, h.ctx, err)
+		}
+	case h.savepoint != "":
+		root := h.txCtx.Root()
+		// The savepoint is the boundary of rollback only marked inside of it
+		if rollbackOnly, _ := root.rollbackState(); rollbackOnly && !h.rollbackOnly {
+			err := root.unexpectedRollbackError()
+			h.rollback()
+			return err
+		}
+		if err := releaseSavePoint(h.txCtx.TxDB(), h.savepoint); err != nil {
+			h.rollback()
+			return err
+		}
+		h.status = TxStatusCommitted
+	default:
+		h.status = TxStatusCommitted
+	}
+	return nil
+}
+
+func (h *txHandle) Rollback() error {
+	if h.status != TxStatusActive {
+		return ErrTransactionCompleted
+	}
+	defer h.complete()
+	h.rollback()
+	return nil
+}
+
+func (h *txHandle) rollback() {
+	switch {
+	case h.begun:
+		h.txCtx.Rollback()
+		h.m.observeCompletion(h.info(), time.Since(h.start), false, false)
+	case h.savepoint != "":
+		root := h.txCtx.Root()
+		h.txCtx.TxDB().RollbackTo(h.savepoint)
+		h.m.observer.OnSavePointRollback(h.info())
+		root.rollbackSynchronizationsTo(h.mark)
+		root.restoreRollbackState(h.rollbackOnly, h.rollbackCause)
+	case h.txCtx != nil:
+		if h.m.globalRollbackOnParticipationFailure {
+			h.txCtx.Root().markRollbackOnly(nil)
+		}
+	}
+	h.status = TxStatusRolledBack
+}
+
+// complete release the resources of the call once it's committed or rolled back
+func (h *txHandle) complete() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	if h.untrack != nil {
+		h.untrack()
+	}
+}
+
+func (h *txHandle) info() TransactionInfo {
+	return h.options.info(h.txCtx.name)
+}
+
+func (h *txHandle) SavePoint(name string) error {
+	if h.status != TxStatusActive {
+		return ErrTransactionCompleted
+	}
+	if h.txCtx == nil {
+		return ErrSavePointWithoutTransaction
+	}
+	return h.txCtx.TxDB().SavePoint(name).Error
+}
+
+func (h *txHandle) RollbackTo(name string) error {
+	if h.status != TxStatusActive {
+		return ErrTransactionCompleted
+	}
+	if h.txCtx == nil {
+		return ErrSavePointWithoutTransaction
+	}
+	return h.txCtx.TxDB().RollbackTo(name).Error
+}
+
+func (h *txHandle) Status() TxStatus {
+	return h.status
+}
@@ -0,0 +1,209 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestTransactionManager_Begin(t *testing.T) {
+
+	var err error
+	DefaultTransactionTest("test-begin-commit",
+		t,
+		func() {
+			ctx, h, beginErr := tm.Begin(context.Background())
+			if beginErr != nil {
+				t.Fatal(beginErr)
+			}
+			if _, ok := FromContext(ctx); !ok {
+				t.Errorf("ctx should be in transaction")
+			}
+			tm.GetDB(ctx).Create(user1)
+
+			// Transaction participates in the transaction begun by Begin
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				return tx.Create(user2).Error
+			}, PropagationMandatory)
+
+			AssertNotExist(user1, t)
+			err = h.Commit()
+			if h.Status() != TxStatusCommitted {
+				t.Errorf("status should be %v, but %v", TxStatusCommitted, h.Status())
+			}
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertExist(user2, t)
+			if err != nil {
+				t.Errorf("error should be nil, but %v", err)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-begin-rollback",
+		t,
+		func() {
+			ctx, h, _ := tm.Begin(context.Background(), PropagationRequiresNew)
+			tm.GetDB(ctx).Create(user1)
+			err = h.Rollback()
+			if h.Status() != TxStatusRolledBack {
+				t.Errorf("status should be %v, but %v", TxStatusRolledBack, h.Status())
+			}
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			if err != nil {
+				t.Errorf("error should be nil, but %v", err)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-begin-nested-rollback",
+		t,
+		func() {
+			ctx, h, _ := tm.Begin(context.Background())
+			tm.GetDB(ctx).Create(user1)
+
+			nestedCtx, nested, _ := tm.Begin(ctx, PropagationNested)
+			tm.GetDB(nestedCtx).Create(user2)
+			_ = SetRollbackOnly(nestedCtx)
+			_ = nested.Rollback()
+
+			err = h.Commit()
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertNotExist(user2, t)
+			if err != nil {
+				t.Errorf("error should be nil, but %v", err)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-begin-participation-rollback",
+		t,
+		func() {
+			ctx, h, _ := tm.Begin(context.Background())
+			tm.GetDB(ctx).Create(user1)
+
+			innerCtx, inner, _ := tm.Begin(ctx, PropagationRequired)
+			tm.GetDB(innerCtx).Create(user2)
+			_ = inner.Rollback()
+
+			err = h.Commit()
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertNotExist(user2, t)
+			if !errors.Is(err, ErrUnexpectedRollback) {
+				t.Errorf("error %v should be %v", err, ErrUnexpectedRollback)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-begin-savepoint",
+		t,
+		func() {
+			ctx, h, _ := tm.Begin(context.Background())
+			tm.GetDB(ctx).Create(user1)
+			_ = h.SavePoint("batch")
+			tm.GetDB(ctx).Create(user2)
+			_ = h.RollbackTo("batch")
+			tm.GetDB(ctx).Create(user3)
+			err = h.Commit()
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertNotExist(user2, t)
+			AssertExist(user3, t)
+			if err != nil {
+				t.Errorf("error should be nil, but %v", err)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-begin-completed",
+		t,
+		func() {
+			_, h, _ := tm.Begin(context.Background())
+			_ = h.Commit()
+			err = h.Rollback()
+		},
+		func(t *testing.T) {
+			AssertErrorsIsEqual(err, ErrTransactionCompleted, t)
+		},
+	)
+
+	DefaultTransactionTest("test-begin-mandatory-without-transaction",
+		t,
+		func() {
+			_, _, err = tm.Begin(context.Background(), PropagationMandatory)
+		},
+		func(t *testing.T) {
+			AssertErrorsIsEqual(err, ErrMandatoryPropWithoutTransaction, t)
+		},
+	)
+
+	DefaultTransactionTest("test-begin-not-supported",
+		t,
+		func() {
+			ctx, h, _ := tm.Begin(context.Background())
+			tm.GetDB(ctx).Create(user1)
+
+			suspendedCtx, suspended, _ := tm.Begin(ctx, PropagationNotSupported)
+			if _, ok := FromContext(suspendedCtx); ok {
+				t.Errorf("ctx should not be in transaction")
+			}
+			err = suspended.SavePoint("batch")
+			_ = suspended.Commit()
+
+			_ = h.Rollback()
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertErrorsIsEqual(err, ErrSavePointWithoutTransaction, t)
+		},
+	)
+
+	DefaultTransactionTest("test-begin-reuse-context",
+		t,
+		func() {
+			// the batch loop pattern: each batch begins a new transaction with the context of the previous one
+			ctx := context.Background()
+			for i, complete := range []func(h TxHandle) error{TxHandle.Commit, TxHandle.Rollback, TxHandle.Commit} {
+				var h TxHandle
+				ctx, h, err = tm.Begin(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if txCtx, _ := FromContext(ctx); !txCtx.IsRoot() {
+					t.Errorf("batch %v should begin a new transaction", i)
+				}
+				tm.GetDB(ctx).Create([]*User{user1, user2, user3}[i])
+				if err = complete(h); err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := FromContext(ctx); ok {
+					t.Errorf("ctx of batch %v should not be in transaction once completed", i)
+				}
+				AssertErrorsIsEqual(RegisterSynchronization(ctx, Synchronization{}), ErrSynchronizationWithoutTransaction, t)
+			}
+
+			// a Transaction call with the context of a completed transaction begins its own
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				return tx.Create(user4).Error
+			})
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertNotExist(user2, t)
+			AssertExist(user3, t)
+			AssertExist(user4, t)
+			if err != nil {
+				t.Errorf("error should be nil, but %v", err)
+			}
+		},
+	)
+}
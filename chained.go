@@ -0,0 +1,240 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strconv"
+)
+
+var ErrHeuristicMixedOutcome = errors.New("heuristic mixed outcome, some transactions committed while others rolled back")
+
+// HeuristicMixedOutcomeError is returned by ChainedTransactionManager when a commit fails after the transactions
+// after it in the chain have already committed
+type HeuristicMixedOutcomeError struct {
+	// Committed list the datasources whose transaction committed
+	Committed []string
+	// RolledBack list the datasources whose transaction rolled back
+	RolledBack []string
+	Err        error
+}
+
+func (e *HeuristicMixedOutcomeError) Error() string {
+	return fmt.Sprintf("%v: committed %v, rolled back %v: %v", ErrHeuristicMixedOutcome, e.Committed, e.RolledBack, e.Err)
+}
+
+func (e *HeuristicMixedOutcomeError) Is(target error) bool {
+	return target == ErrHeuristicMixedOutcome
+}
+
+func (e *HeuristicMixedOutcomeError) Unwrap() error {
+	return e.Err
+}
+
+// ChainedTransactionManager compose several TransactionManagers into a best-effort transaction across databases.
+// Transactions are begun in order and committed in reverse order, all of them roll back when bizFn fails. A commit
+// failure after some transactions have committed can't be undone and is reported as HeuristicMixedOutcomeError.
+type ChainedTransactionManager struct {
+	managers []TransactionManager
+}
+
+// NewChainedTransactionManager panics when two of managers share a datasource, their transactions would be bound to a
+// context under the same key and merged into one, see WithDataSource
+func NewChainedTransactionManager(managers ...TransactionManager) *ChainedTransactionManager {
+	if len(managers) == 0 {
+		panic("chained transaction manager needs at least one TransactionManager")
+	}
+	datasources := make(map[string]bool, len(managers))
+	for _, manager := range managers {
+		datasource, ok := dataSourceOf(manager)
+		if !ok {
+			continue
+		}
+		if datasources[datasource] {
+			panic(fmt.Sprintf("chained transaction managers share datasource %q, name them with WithDataSource", datasource))
+		}
+		datasources[datasource] = true
+	}
+	return &ChainedTransactionManager{
+		managers: managers,
+	}
+}
+
+// dataSourceOf return the datasource of tm, unwrapping the TransactionManagers decorating another one with an
+// Unwrap() TransactionManager method
+func dataSourceOf(tm TransactionManager) (string, bool) {
+	for {
+		switch m := tm.(type) {
+		case *transactionManager:
+			return m.key.datasource, true
+		case interface{ Unwrap() TransactionManager }:
+			tm = m.Unwrap()
+		default:
+			return "", false
+		}
+	}
+}
+
+// GetDB return gorm.DB of the first TransactionManager with ctx
+func (m *ChainedTransactionManager) GetDB(ctx context.Context) *gorm.DB {
+	return m.managers[0].GetDB(ctx)
+}
+
+// GetOriginDB return original gorm.DB object of the first TransactionManager
+func (m *ChainedTransactionManager) GetOriginDB() *gorm.DB {
+	return m.managers[0].GetOriginDB()
+}
+
+// Transaction execute bizFn with the transactions of all TransactionManagers bound to ctx, tx is the transaction
+// of the first one, use GetDB of each TransactionManager to reach the others. opts are passed to every
+// TransactionManager except WithRetry, which re-runs the whole chain.
+func (m *ChainedTransactionManager) Transaction(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, opts ...TransactionOption) error {
+	options := newTransactionOptions(opts)
+	opts = append(opts[:len(opts):len(opts)], transactionOptionFunc(func(options *transactionOptions) {
+		options.retry = nil
+	}))
+	if options.retry != nil {
+		return options.retry.execute(ctx, func() error {
+			return m.execute(ctx, bizFn, opts)
+		}, func(attempt int, err error) {})
+	}
+	return m.execute(ctx, bizFn, opts)
+}
+
+func (m *ChainedTransactionManager) execute(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, opts []TransactionOption) error {
+	names := make([]string, len(m.managers))
+	completed := make([]bool, len(m.managers))
+	err := m.begin(ctx, 0, nil, bizFn, opts, names, completed)
+	if completed[0] || !completed[len(m.managers)-1] {
+		return err
+	}
+	return mixedOutcome(names, completed, err)
+}
+
+// mixedOutcome list the datasources whose transaction committed or rolled back, #i stands for the i-th
+// TransactionManager when its datasource is unknown
+func mixedOutcome(names []string, completed []bool, err error) *HeuristicMixedOutcomeError {
+	mixed := &HeuristicMixedOutcomeError{Err: err}
+	for i := range names {
+		name := names[i]
+		if name == "" {
+			name = "#" + strconv.Itoa(i)
+		}
+		if completed[i] {
+			mixed.Committed = append(mixed.Committed, name)
+		} else {
+			mixed.RolledBack = append(mixed.RolledBack, name)
+		}
+	}
+	return mixed
+}
+
+// begin execute the rest of the chain from the i-th TransactionManager inside its transaction, so the transaction
+// of the i-th one completes after all the ones after it
+func (m *ChainedTransactionManager) begin(ctx context.Context, i int, first *gorm.DB, bizFn func(ctx context.Context, tx *gorm.DB) error, opts []TransactionOption, names []string, completed []bool) error {
+	if i == len(m.managers) {
+		return bizFn(ctx, first)
+	}
+	err := m.managers[i].Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		if txCtx, ok := FromContext(ctx); ok && txCtx.TxDB() == tx {
+			names[i] = txCtx.DataSource()
+		}
+		if i == 0 {
+			first = tx
+		}
+		return m.begin(ctx, i+1, first, bizFn, opts, names, completed)
+	}, opts...)
+	completed[i] = err == nil || !newTransactionOptions(opts).rollbackOn(err)
+	return err
+}
+
+// Begin start a call on every TransactionManager in order, the returned TxHandle commits them in reverse order and
+// reports a commit failure after some of them have committed as HeuristicMixedOutcomeError, like Transaction does.
+// The returned context is bound to the transactions of all TransactionManagers. WithRetry is ignored.
+func (m *ChainedTransactionManager) Begin(ctx context.Context, opts ...TransactionOption) (context.Context, TxHandle, error) {
+	h := &chainedTxHandle{
+		handles: make([]TxHandle, 0, len(m.managers)),
+		names:   make([]string, len(m.managers)),
+	}
+	next := ctx
+	for i, manager := range m.managers {
+		txCtx, handle, err := manager.Begin(next, opts...)
+		if err != nil {
+			_ = h.Rollback()
+			return ctx, nil, err
+		}
+		if current, ok := FromContext(txCtx); ok && txCtx != next {
+			h.names[i] = current.DataSource()
+		}
+		h.handles = append(h.handles, handle)
+		next = txCtx
+	}
+	return next, h, nil
+}
+
+type chainedTxHandle struct {
+	handles []TxHandle
+	names   []string
+	status  TxStatus
+}
+
+func (h *chainedTxHandle) Commit() error {
+	if h.status != TxStatusActive {
+		return ErrTransactionCompleted
+	}
+	completed := make([]bool, len(h.handles))
+	for i := len(h.handles) - 1; i >= 0; i-- {
+		if err := h.handles[i].Commit(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				_ = h.handles[j].Rollback()
+			}
+			h.status = TxStatusRolledBack
+			if i == len(h.handles)-1 {
+				return err
+			}
+			return mixedOutcome(h.names, completed, err)
+		}
+		completed[i] = true
+	}
+	h.status = TxStatusCommitted
+	return nil
+}
+
+func (h *chainedTxHandle) Rollback() error {
+	if h.status != TxStatusActive {
+		return ErrTransactionCompleted
+	}
+	var err error
+	for i := len(h.handles) - 1; i >= 0; i-- {
+		if rollbackErr := h.handles[i].Rollback(); err == nil {
+			err = rollbackErr
+		}
+	}
+	h.status = TxStatusRolledBack
+	return err
+}
+
+// SavePoint create a savepoint named name in the transactions of all TransactionManagers
+func (h *chainedTxHandle) SavePoint(name string) error {
+	for _, handle := range h.handles {
+		if err := handle.SavePoint(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RollbackTo roll back the transactions of all TransactionManagers to the savepoint named name
+func (h *chainedTxHandle) RollbackTo(name string) error {
+	for _, handle := range h.handles {
+		if err := handle.RollbackTo(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *chainedTxHandle) Status() TxStatus {
+	return h.status
+}
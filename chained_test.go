@@ -0,0 +1,163 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"github.com/storm-blue/gorm-transaction/internal/sqlitetest"
+	"gorm.io/gorm"
+	"reflect"
+	"testing"
+)
+
+func TestChainedTransactionManager(t *testing.T) {
+	orders := NewTransactionManager(db, WithDataSource("orders"))
+	stock := NewTransactionManager(db, WithDataSource("stock"))
+	chained := NewChainedTransactionManager(orders, stock)
+
+	DefaultTransactionTest("test-chained-commit",
+		t,
+		func() {
+			err := chained.Transaction(context.Background(), func(ctx context.Context, tx *gorm.DB) error {
+				if _, ok := FromDataSourceContext(ctx, "orders"); !ok {
+					t.Errorf("transaction of orders should be bound to ctx")
+				}
+				if _, ok := FromDataSourceContext(ctx, "stock"); !ok {
+					t.Errorf("transaction of stock should be bound to ctx")
+				}
+				return stock.GetDB(ctx).Create(user1).Error
+			})
+			if err != nil {
+				t.Errorf("error should be nil, but %v", err)
+			}
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+		},
+	)
+
+	DefaultTransactionTest("test-chained-rollback",
+		t,
+		func() {
+			err := chained.Transaction(context.Background(), func(ctx context.Context, tx *gorm.DB) error {
+				stock.GetDB(ctx).Create(user1)
+				return mockErr
+			})
+			AssertErrorsIsEqual(mockErr, err, t)
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+		},
+	)
+
+	DefaultTransactionTest("test-chained-mixed-outcome",
+		t,
+		func() {
+			err := chained.Transaction(context.Background(), func(ctx context.Context, tx *gorm.DB) error {
+				// the transaction of orders commits after the one of stock
+				txCtx, _ := FromDataSourceContext(ctx, "orders")
+				_ = RegisterSynchronization(txCtx, Synchronization{
+					BeforeCommit: func(ctx context.Context) error {
+						return mockErr
+					},
+				})
+				return stock.GetDB(ctx).Create(user1).Error
+			})
+			if !errors.Is(err, ErrHeuristicMixedOutcome) || !errors.Is(err, mockErr) {
+				t.Errorf("error should be %v caused by %v, but %v", ErrHeuristicMixedOutcome, mockErr, err)
+			}
+			var mixed *HeuristicMixedOutcomeError
+			if errors.As(err, &mixed) {
+				if !reflect.DeepEqual(mixed.Committed, []string{"stock"}) || !reflect.DeepEqual(mixed.RolledBack, []string{"orders"}) {
+					t.Errorf("stock should be committed and orders rolled back, but %v", err)
+				}
+			}
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+		},
+	)
+
+	DefaultTransactionTest("test-chained-begin-rollback",
+		t,
+		func() {
+			ctx, h, err := chained.Begin(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			stock.GetDB(ctx).Create(user1)
+			if err := h.Rollback(); err != nil {
+				t.Errorf("error should be nil, but %v", err)
+			}
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+		},
+	)
+
+	DefaultTransactionTest("test-chained-begin-mixed-outcome",
+		t,
+		func() {
+			ctx, h, _ := chained.Begin(context.Background())
+			txCtx, _ := FromDataSourceContext(ctx, "orders")
+			_ = RegisterSynchronization(txCtx, Synchronization{
+				BeforeCommit: func(ctx context.Context) error {
+					return mockErr
+				},
+			})
+			stock.GetDB(ctx).Create(user1)
+
+			err := h.Commit()
+			var mixed *HeuristicMixedOutcomeError
+			if !errors.As(err, &mixed) || !errors.Is(err, mockErr) {
+				t.Fatalf("error should be %v caused by %v, but %v", ErrHeuristicMixedOutcome, mockErr, err)
+			}
+			if !reflect.DeepEqual(mixed.Committed, []string{"stock"}) || !reflect.DeepEqual(mixed.RolledBack, []string{"orders"}) {
+				t.Errorf("stock should be committed and orders rolled back, but %v", err)
+			}
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+		},
+	)
+}
+
+func TestChainedTransactionManager_DataSources(t *testing.T) {
+	open := func() *gorm.DB {
+		db, err := sqlitetest.Open(t.TempDir(), User{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	db1, db2 := open(), open()
+
+	t.Run("test-chained-shared-datasource", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("managers sharing a datasource should be rejected")
+			}
+		}()
+		NewChainedTransactionManager(NewTransactionManager(db1), NewTransactionManager(db2))
+	})
+
+	t.Run("test-chained-distinct-datasources", func(t *testing.T) {
+		a := NewTransactionManager(db1, WithDataSource("a"))
+		b := NewTransactionManager(db2, WithDataSource("b"))
+		u := &User{Username: "chained_user"}
+		err := NewChainedTransactionManager(a, b).Transaction(context.Background(), func(ctx context.Context, tx *gorm.DB) error {
+			if b.GetDB(ctx).Statement.ConnPool == a.GetDB(ctx).Statement.ConnPool {
+				t.Errorf("transactions of a and b should be distinct")
+			}
+			return b.GetDB(ctx).Create(u).Error
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n1, n2 int64
+		db1.Model(User{}).Where("id = ?", u.ID).Count(&n1)
+		db2.Model(User{}).Where("id = ?", u.ID).Count(&n2)
+		if n1 != 0 || n2 != 1 {
+			t.Errorf("user should be written to db2 only, but db1: %v, db2: %v", n1, n2)
+		}
+	})
+}
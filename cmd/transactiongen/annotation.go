@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const annotationPrefix = "//transaction:"
+
+var propagations = map[string]string{
+	"REQUIRED":      "transaction.PropagationRequired",
+	"SUPPORTS":      "transaction.PropagationSupports",
+	"MANDATORY":     "transaction.PropagationMandatory",
+	"REQUIRES_NEW":  "transaction.PropagationRequiresNew",
+	"NOT_SUPPORTED": "transaction.PropagationNotSupported",
+	"NESTED":        "transaction.PropagationNested",
+	"NEVER":         "transaction.PropagationNever",
+}
+
+var isolations = map[string]string{
+	"DEFAULT":          "sql.LevelDefault",
+	"READ_UNCOMMITTED": "sql.LevelReadUncommitted",
+	"READ_COMMITTED":   "sql.LevelReadCommitted",
+	"WRITE_COMMITTED":  "sql.LevelWriteCommitted",
+	"REPEATABLE_READ":  "sql.LevelRepeatableRead",
+	"SNAPSHOT":         "sql.LevelSnapshot",
+	"SERIALIZABLE":     "sql.LevelSerializable",
+	"LINEARIZABLE":     "sql.LevelLinearizable",
+}
+
+// policy is the transaction policy declared by an annotation
+type policy struct {
+	propagation string
+	isolation   string
+	readOnly    bool
+	timeout     time.Duration
+	name        string
+	retry       bool
+}
+
+// parseAnnotation parse the text of an annotation without annotationPrefix, e.g. propagation=REQUIRES_NEW,readonly
+func parseAnnotation(text string) (*policy, error) {
+	p := &policy{}
+	for _, item := range strings.Split(text, ",") {
+		key, value, hasValue := strings.Cut(strings.TrimSpace(item), "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "":
+			continue
+		case "propagation":
+			if p.propagation = propagations[value]; p.propagation == "" {
+				return nil, fmt.Errorf("unknown propagation %q", value)
+			}
+		case "isolation":
+			if p.isolation = isolations[value]; p.isolation == "" {
+				return nil, fmt.Errorf("unknown isolation level %q", value)
+			}
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("invalid timeout %q", value)
+			}
+			p.timeout = timeout
+		case "name":
+			if value == "" {
+				return nil, fmt.Errorf("empty name")
+			}
+			p.name = value
+		case "readonly", "retry":
+			if hasValue {
+				return nil, fmt.Errorf("%v doesn't take a value", key)
+			}
+			p.readOnly = p.readOnly || key == "readonly"
+			p.retry = p.retry || key == "retry"
+		default:
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	return p, nil
+}
+
+// options return the TransactionOption expressions of the policy
+func (p *policy) options() []string {
+	var opts []string
+	if p.propagation != "" {
+		opts = append(opts, p.propagation)
+	}
+	if p.isolation != "" {
+		opts = append(opts, "transaction.WithIsolationLevel("+p.isolation+")")
+	}
+	if p.readOnly {
+		opts = append(opts, "transaction.WithReadOnly()")
+	}
+	if p.timeout > 0 {
+		opts = append(opts, "transaction.WithTimeout("+durationExpr(p.timeout)+")")
+	}
+	if p.name != "" {
+		opts = append(opts, "transaction.WithName("+strconv.Quote(p.name)+")")
+	}
+	if p.retry {
+		opts = append(opts, "transaction.WithRetry(transaction.DefaultRetryPolicy())")
+	}
+	return opts
+}
+
+func durationExpr(d time.Duration) string {
+	units := []struct {
+		unit time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	}
+	for _, u := range units {
+		if d%u.unit == 0 {
+			return strconv.FormatInt(int64(d/u.unit), 10) + " * " + u.name
+		}
+	}
+	return strconv.FormatInt(int64(d), 10) + " * time.Nanosecond"
+}
@@ -0,0 +1,28 @@
+// Package example declare an interface decorated by transactiongen, it's the golden input of the generator tests.
+package example
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+//go:generate go run github.com/storm-blue/gorm-transaction/cmd/transactiongen -type OrderService
+
+type Order struct {
+	ID        int64
+	CreatedAt time.Time
+}
+
+type OrderService interface {
+	//transaction:propagation=REQUIRES_NEW,readonly,timeout=5s
+	GetOrder(ctx context.Context, id int64) (*Order, error)
+	// PlaceOrder create an order with items
+	//transaction:name=PlaceOrder,isolation=SERIALIZABLE,retry
+	PlaceOrder(c context.Context, items ...string) (order *Order, total int64, err error)
+	//transaction:propagation=NESTED
+	CancelOrder(ctx context.Context, id int64, d time.Duration) error
+	//transaction:
+	Export(ctx context.Context, w io.Writer) (n int, orders []*Order, total int64, err error)
+	Close() error
+}
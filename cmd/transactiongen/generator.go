@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const transactionPath = "github.com/storm-blue/gorm-transaction"
+
+// builtinImports are the imports the generated code may use besides the ones of the method signatures
+var builtinImports = map[string]string{
+	"context":     "context",
+	"sql":         "database/sql",
+	"gorm":        "gorm.io/gorm",
+	"time":        "time",
+	"transaction": transactionPath,
+}
+
+type param struct {
+	name     string
+	typ      string
+	variadic bool
+}
+
+type method struct {
+	name    string
+	params  []param
+	results []string
+	policy  *policy
+}
+
+type decorator struct {
+	iface   string
+	methods []method
+}
+
+// generate the decorators of the interfaces types declared in the package of dir
+func generate(dir string, types []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expect exactly one package in %v, found %v", dir, len(pkgs))
+	}
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+
+	g := &generator{
+		fset:    fset,
+		imports: make(map[string]string),
+	}
+	for _, name := range types {
+		iface, file := findInterface(pkg, name)
+		if iface == nil {
+			return nil, fmt.Errorf("interface %v not found in %v", name, dir)
+		}
+		d, err := g.parseInterface(name, iface, file)
+		if err != nil {
+			return nil, err
+		}
+		g.decorators = append(g.decorators, d)
+	}
+	return g.render(pkg.Name)
+}
+
+func findInterface(pkg *ast.Package, name string) (*ast.InterfaceType, *ast.File) {
+	fileNames := make([]string, 0, len(pkg.Files))
+	for fileName := range pkg.Files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	for _, fileName := range fileNames {
+		file := pkg.Files[fileName]
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if iface, ok := typeSpec.Type.(*ast.InterfaceType); ok && typeSpec.Name.Name == name && typeSpec.TypeParams == nil {
+					return iface, file
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+type generator struct {
+	fset       *token.FileSet
+	decorators []decorator
+	// imports used by the generated code, keyed by package name
+	imports map[string]string
+}
+
+func (g *generator) parseInterface(name string, iface *ast.InterfaceType, file *ast.File) (decorator, error) {
+	d := decorator{iface: name}
+	g.use("transaction", file)
+	for _, field := range iface.Methods.List {
+		fn, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) != 1 {
+			return d, fmt.Errorf("%v: embedded interfaces are not supported", name)
+		}
+		m := method{name: field.Names[0].Name}
+		p, err := annotation(field.Doc)
+		if err != nil {
+			return d, fmt.Errorf("%v.%v: %w", name, m.name, err)
+		}
+		m.policy = p
+		if err := g.parseSignature(&m, fn, file); err != nil {
+			return d, fmt.Errorf("%v.%v: %w", name, m.name, err)
+		}
+		d.methods = append(d.methods, m)
+	}
+	return d, nil
+}
+
+func annotation(doc *ast.CommentGroup) (*policy, error) {
+	if doc == nil {
+		return nil, nil
+	}
+	var p *policy
+	for _, comment := range doc.List {
+		if !strings.HasPrefix(comment.Text, annotationPrefix) {
+			continue
+		}
+		if p != nil {
+			return nil, fmt.Errorf("duplicate annotation")
+		}
+		var err error
+		if p, err = parseAnnotation(strings.TrimPrefix(comment.Text, annotationPrefix)); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+var (
+	reservedNames = regexp.MustCompile(`^(_|d|ctx|tx|err|[rz]\d+|context|sql|gorm|time|transaction)$`)
+	versionSuffix = regexp.MustCompile(`^v\d+$`)
+)
+
+func (g *generator) parseSignature(m *method, fn *ast.FuncType, file *ast.File) error {
+	used := make(map[string]bool)
+	for _, field := range fn.Params.List {
+		for _, ident := range field.Names {
+			used[ident.Name] = true
+		}
+	}
+	for _, field := range fn.Params.List {
+		typ := g.typeString(field.Type, file)
+		_, variadic := field.Type.(*ast.Ellipsis)
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{{Name: "_"}}
+		}
+		for _, ident := range names {
+			name := ident.Name
+			if len(m.params) == 0 {
+				name = "ctx"
+			} else {
+				for reservedNames.MatchString(name) || (name != ident.Name && used[name]) {
+					if name == "_" {
+						name = "p" + strconv.Itoa(len(m.params))
+					} else {
+						name += "_"
+					}
+				}
+			}
+			used[name] = true
+			m.params = append(m.params, param{name: name, typ: typ, variadic: variadic})
+		}
+	}
+	if fn.Results != nil {
+		for _, field := range fn.Results.List {
+			typ := g.typeString(field.Type, file)
+			for i := 0; i < max(len(field.Names), 1); i++ {
+				m.results = append(m.results, typ)
+			}
+		}
+	}
+
+	if m.policy == nil {
+		return nil
+	}
+	if len(m.params) == 0 || m.params[0].typ != "context.Context" {
+		return fmt.Errorf("the first parameter must be context.Context")
+	}
+	if len(m.results) == 0 || m.results[len(m.results)-1] != "error" {
+		return fmt.Errorf("the last result must be error")
+	}
+	g.use("context", file)
+	g.use("gorm", file)
+	if m.policy.timeout > 0 {
+		g.use("time", file)
+	}
+	if m.policy.isolation != "" {
+		g.use("sql", file)
+	}
+	return nil
+}
+
+// typeString print expr and record the imports it refers to
+func (g *generator) typeString(expr ast.Expr, file *ast.File) string {
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				g.use(ident.Name, file)
+			}
+			return false
+		}
+		return true
+	})
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, g.fset, expr)
+	return buf.String()
+}
+
+// use record the import of package name, looked up in the imports of file before builtinImports
+func (g *generator) use(name string, file *ast.File) {
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if spec.Name != nil && spec.Name.Name == name || spec.Name == nil && packageName(importPath) == name {
+			g.imports[name] = importPath
+			return
+		}
+	}
+	if importPath, ok := builtinImports[name]; ok {
+		g.imports[name] = importPath
+	}
+}
+
+// packageName guess the name of the package of importPath, e.g. yaml for gopkg.in/yaml.v3
+func packageName(importPath string) string {
+	name := path.Base(importPath)
+	if versionSuffix.MatchString(name) {
+		name = path.Base(path.Dir(importPath))
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexAny(name, ".-"); i >= 0 {
+		name = name[:i]
+	}
+	if importPath == transactionPath {
+		name = "transaction"
+	}
+	return name
+}
+
+func (g *generator) render(pkgName string) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by transactiongen. DO NOT EDIT.\n\npackage %v\n\nimport (\n", pkgName)
+	names := make([]string, 0, len(g.imports))
+	for name := range g.imports {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return g.imports[names[i]] < g.imports[names[j]]
+	})
+	for _, name := range names {
+		importPath := g.imports[name]
+		if packageName(importPath) == name && path.Base(importPath) == name {
+			fmt.Fprintf(&b, "\t%q\n", importPath)
+		} else {
+			fmt.Fprintf(&b, "\t%v %q\n", name, importPath)
+		}
+	}
+	b.WriteString(")\n")
+
+	for _, d := range g.decorators {
+		typeName := "Transactional" + d.iface
+		fmt.Fprintf(&b, "\n// %v run the methods of %v in transactions as declared by their annotations\n", typeName, d.iface)
+		fmt.Fprintf(&b, "type %v struct {\n\tnext %v\n\ttm transaction.TransactionManager\n}\n\n", typeName, d.iface)
+		fmt.Fprintf(&b, "var _ %v = (*%v)(nil)\n\n", d.iface, typeName)
+		fmt.Fprintf(&b, "func New%v(next %v, tm transaction.TransactionManager) *%v {\n", typeName, d.iface, typeName)
+		fmt.Fprintf(&b, "\treturn &%v{next: next, tm: tm}\n}\n", typeName)
+		for _, m := range d.methods {
+			b.WriteString("\n")
+			renderMethod(&b, typeName, m)
+		}
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+func renderMethod(b *bytes.Buffer, typeName string, m method) {
+	var params, args []string
+	for _, p := range m.params {
+		params = append(params, p.name+" "+p.typ)
+		if p.variadic {
+			args = append(args, p.name+"...")
+		} else {
+			args = append(args, p.name)
+		}
+	}
+	results := strings.Join(m.results, ", ")
+	if len(m.results) > 1 {
+		results = "(" + results + ")"
+	}
+	call := fmt.Sprintf("d.next.%v(%v)", m.name, strings.Join(args, ", "))
+	fmt.Fprintf(b, "func (d *%v) %v(%v) %v {\n", typeName, m.name, strings.Join(params, ", "), results)
+	defer b.WriteString("}\n")
+
+	if m.policy == nil {
+		if len(m.results) > 0 {
+			call = "return " + call
+		}
+		fmt.Fprintf(b, "\t%v\n", call)
+		return
+	}
+
+	opts := ""
+	if options := m.policy.options(); len(options) > 0 {
+		opts = ", " + strings.Join(options, ", ")
+	}
+	values := m.results[:len(m.results)-1]
+	switch len(values) {
+	case 0:
+		fmt.Fprintf(b, "\treturn d.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {\n\t\treturn %v\n\t}%v)\n", call, opts)
+	case 1, 2:
+		execute := "transaction.Execute"
+		if len(values) == 2 {
+			execute = "transaction.Execute2"
+		}
+		fmt.Fprintf(b, "\treturn %v(ctx, d.tm, func(ctx context.Context, tx *gorm.DB) %v {\n\t\treturn %v\n\t}%v)\n", execute, results, call, opts)
+	default:
+		var vars, zeros []string
+		for i, typ := range values {
+			fmt.Fprintf(b, "\tvar r%d %v\n", i, typ)
+			vars = append(vars, "r"+strconv.Itoa(i))
+			zeros = append(zeros, "z"+strconv.Itoa(i))
+		}
+		fmt.Fprintf(b, "\terr := d.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {\n\t\tvar err error\n")
+		fmt.Fprintf(b, "\t\t%v, err = %v\n\t\treturn err\n\t}%v)\n", strings.Join(vars, ", "), call, opts)
+		fmt.Fprintf(b, "\tif err != nil {\n")
+		for i, typ := range values {
+			fmt.Fprintf(b, "\t\tvar z%d %v\n", i, typ)
+		}
+		fmt.Fprintf(b, "\t\treturn %v, err\n\t}\n\treturn %v, nil\n", strings.Join(zeros, ", "), strings.Join(vars, ", "))
+	}
+}
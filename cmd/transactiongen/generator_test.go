@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerate(t *testing.T) {
+	src, err := generate("example", []string{"OrderService"})
+	if err != nil {
+		t.Fatalf("error should be nil, but %v", err)
+	}
+	golden, _ := os.ReadFile(filepath.Join("example", "order_service_transaction.go"))
+	if string(src) != string(golden) {
+		t.Errorf("generated code should equal example/order_service_transaction.go, run go generate ./... to update it:\n%s", src)
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	cases := map[string]string{
+		"no-context": `
+	//transaction:propagation=REQUIRED
+	Get(id int64) error`,
+		"no-error": `
+	//transaction:propagation=REQUIRED
+	Get(ctx context.Context) int64`,
+		"unknown-key": `
+	//transaction:propagation=REQUIRED,lazy
+	Get(ctx context.Context) error`,
+		"duplicate": `
+	//transaction:propagation=REQUIRED
+	//transaction:readonly
+	Get(ctx context.Context) error`,
+	}
+	for name, methods := range cases {
+		dir := t.TempDir()
+		src := "package service\n\nimport \"context\"\n\ntype Service interface {" + methods + "\n}\n"
+		if err := os.WriteFile(filepath.Join(dir, "service.go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := generate(dir, []string{"Service"}); err == nil || !strings.Contains(err.Error(), "Service.Get") {
+			t.Errorf("%v: error of Service.Get should be returned, but %v", name, err)
+		}
+	}
+	if _, err := generate("example", []string{"Missing"}); err == nil {
+		t.Errorf("error should be returned for missing interface")
+	}
+}
+
+func TestParseAnnotation(t *testing.T) {
+	p, err := parseAnnotation("propagation=REQUIRES_NEW, readonly,timeout=1500ms,isolation=READ_COMMITTED,name=A/B,retry")
+	if err != nil {
+		t.Fatalf("error should be nil, but %v", err)
+	}
+	expected := policy{
+		propagation: "transaction.PropagationRequiresNew",
+		isolation:   "sql.LevelReadCommitted",
+		readOnly:    true,
+		timeout:     1500 * time.Millisecond,
+		name:        "A/B",
+		retry:       true,
+	}
+	if *p != expected {
+		t.Errorf("policy should be %+v, but %+v", expected, *p)
+	}
+
+	for _, text := range []string{"propagation=LAZY", "isolation=", "timeout=soon", "readonly=true", "name="} {
+		if _, err := parseAnnotation(text); err == nil {
+			t.Errorf("%v should be invalid", text)
+		}
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"OrderService":     "order_service",
+		"HTTPOrderService": "http_order_service",
+		"Service":          "service",
+	} {
+		if actual := snakeCase(name); actual != expected {
+			t.Errorf("snake case of %v should be %v, but %v", name, expected, actual)
+		}
+	}
+}
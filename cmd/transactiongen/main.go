@@ -0,0 +1,73 @@
+// Command transactiongen generate decorators running the methods of an interface in transactions, as declared by
+// //transaction: annotations on the methods:
+//
+//	//go:generate go run github.com/storm-blue/gorm-transaction/cmd/transactiongen -type OrderService
+//	type OrderService interface {
+//		//transaction:propagation=REQUIRES_NEW,readonly,timeout=5s
+//		GetOrder(ctx context.Context, id int64) (*Order, error)
+//	}
+//
+// generates TransactionalOrderService, created by NewTransactionalOrderService(next, tm). Annotated methods must
+// take a context.Context first and return an error last, methods without annotation are delegated as is. The keys of
+// an annotation are:
+//
+//	propagation=REQUIRED|SUPPORTS|MANDATORY|REQUIRES_NEW|NOT_SUPPORTED|NESTED|NEVER
+//	isolation=READ_UNCOMMITTED|READ_COMMITTED|WRITE_COMMITTED|REPEATABLE_READ|SNAPSHOT|SERIALIZABLE|LINEARIZABLE
+//	readonly
+//	timeout=<duration>
+//	name=<transaction name>
+//	retry (transaction.DefaultRetryPolicy)
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of interface names, required")
+	output := flag.String("output", "", "output file name, default <type>_transaction.go")
+	dir := flag.String("dir", ".", "directory of the package declaring the interfaces")
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	types := strings.Split(*typeNames, ",")
+	src, err := generate(*dir, types)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "transactiongen: %v\n", err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		*output = snakeCase(types[0]) + "_transaction.go"
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *output), src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "transactiongen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// snakeCase convert name to snake case, e.g. HTTPOrderService to http_order_service
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if isUpper(c) {
+			if i > 0 && (!isUpper(name[i-1]) || i+1 < len(name) && !isUpper(name[i+1])) {
+				b.WriteByte('_')
+			}
+			c += 'a' - 'A'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isUpper(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
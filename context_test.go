@@ -0,0 +1,139 @@
+package transaction
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+type contextKey struct{}
+
+func TestTransactionManager_Transaction_DerivedContext(t *testing.T) {
+
+	DefaultTransactionTest("test-transaction-context-direct",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tm.GetDB(ctx).Create(user1)
+
+				_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, PropagationMandatory)
+
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertNotExist(user2, t)
+		},
+	)
+
+	DefaultTransactionTest("test-with-value-participate",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				ctx = context.WithValue(ctx, contextKey{}, "value")
+				tm.GetDB(ctx).Create(user1)
+
+				_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					if ctx.Value(contextKey{}) != "value" {
+						t.Errorf("value of derived context should be kept")
+					}
+					tx.Create(user2)
+					return nil
+				}, PropagationMandatory)
+
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertNotExist(user2, t)
+		},
+	)
+
+	DefaultTransactionTest("test-with-timeout-participate",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				ctx, cancel := context.WithTimeout(ctx, time.Minute)
+				defer cancel()
+				tm.GetDB(ctx).Create(user1)
+
+				_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					if _, ok := ctx.Deadline(); !ok {
+						t.Errorf("deadline of derived context should be kept")
+					}
+					tx.Create(user2)
+					return nil
+				})
+
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertNotExist(user2, t)
+		},
+	)
+
+	var err error
+	DefaultTransactionTest("test-with-value-never",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				err = tm.Transaction(context.WithValue(ctx, contextKey{}, "value"), func(ctx context.Context, tx *gorm.DB) error {
+					return nil
+				}, PropagationNever)
+				return err
+			})
+		},
+		func(t *testing.T) {
+			AssertErrorsIsEqual(err, ErrNeverPropInTransaction, t)
+		},
+	)
+
+	DefaultTransactionTest("test-with-value-not-supported",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = tm.Transaction(context.WithValue(ctx, contextKey{}, "value"), func(ctx context.Context, tx *gorm.DB) error {
+					if _, ok := FromContext(ctx); ok {
+						t.Errorf("transaction should be suspended")
+					}
+					tm.GetDB(ctx).Create(user1)
+					return nil
+				}, PropagationNotSupported)
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+		},
+	)
+
+	DefaultTransactionTest("test-from-context",
+		t,
+		func() {
+			if _, ok := FromContext(context.Background()); ok {
+				t.Errorf("background context should not be in transaction")
+			}
+			_ = tm.Transaction(context.Background(), func(ctx context.Context, tx *gorm.DB) error {
+				txCtx, ok := FromContext(context.WithValue(ctx, contextKey{}, "value"))
+				if !ok || !txCtx.IsRoot() || txCtx.TxDB() != tx {
+					t.Errorf("derived context should be in transaction")
+				}
+				return nil
+			})
+		},
+		func(t *testing.T) {},
+	)
+}
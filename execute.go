@@ -0,0 +1,38 @@
+package transaction
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+// Execute run fn with tm.Transaction and return its result, the zero value is returned when the transaction fails
+func Execute[T any](ctx context.Context, tm TransactionManager, fn func(ctx context.Context, tx *gorm.DB) (T, error), opts ...TransactionOption) (T, error) {
+	var result T
+	err := tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		var err error
+		result, err = fn(ctx, tx)
+		return err
+	}, opts...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// Execute2 is Execute for functions returning two values
+func Execute2[T1, T2 any](ctx context.Context, tm TransactionManager, fn func(ctx context.Context, tx *gorm.DB) (T1, T2, error), opts ...TransactionOption) (T1, T2, error) {
+	var result1 T1
+	var result2 T2
+	err := tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		var err error
+		result1, result2, err = fn(ctx, tx)
+		return err
+	}, opts...)
+	if err != nil {
+		var zero1 T1
+		var zero2 T2
+		return zero1, zero2, err
+	}
+	return result1, result2, nil
+}
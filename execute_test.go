@@ -0,0 +1,96 @@
+package transaction
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestExecute(t *testing.T) {
+
+	var id uint
+	var err error
+	DefaultTransactionTest("test-execute-commit",
+		t,
+		func() {
+			ctx := context.Background()
+			id, err = Execute(ctx, tm, func(ctx context.Context, tx *gorm.DB) (uint, error) {
+				user := &User{Username: user1.Username, CreateTime: user1.CreateTime}
+				err := tx.Create(user).Error
+				return user.ID, err
+			})
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			if err != nil || id == 0 {
+				t.Errorf("unexpected result: %v, %v", id, err)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-execute-rollback-zero-value",
+		t,
+		func() {
+			ctx := context.Background()
+			id, err = Execute(ctx, tm, func(ctx context.Context, tx *gorm.DB) (uint, error) {
+				user := &User{Username: user1.Username, CreateTime: user1.CreateTime}
+				tx.Create(user)
+				return user.ID, mockErr
+			})
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertErrorsIsEqual(err, mockErr, t)
+			if id != 0 {
+				t.Errorf("result %v should be zero value", id)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-execute-participate",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				id, err = Execute(ctx, tm, func(ctx context.Context, tx *gorm.DB) (uint, error) {
+					user := &User{Username: user2.Username, CreateTime: user2.CreateTime}
+					err := tx.Create(user).Error
+					return user.ID, err
+				}, PropagationMandatory)
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertNotExist(user2, t)
+			if err != nil || id == 0 {
+				t.Errorf("unexpected result: %v, %v", id, err)
+			}
+		},
+	)
+
+	var users []User
+	var count int64
+	DefaultTransactionTest("test-execute2",
+		t,
+		func() {
+			ctx := context.Background()
+			db.Create(user1)
+			users, count, err = Execute2(ctx, tm, func(ctx context.Context, tx *gorm.DB) ([]User, int64, error) {
+				var users []User
+				var count int64
+				if err := tx.Find(&users).Error; err != nil {
+					return nil, 0, err
+				}
+				err := tx.Model(&User{}).Count(&count).Error
+				return users, count, err
+			}, PropagationSupports, WithReadOnly())
+		},
+		func(t *testing.T) {
+			if err != nil || len(users) != 1 || count != 1 {
+				t.Errorf("unexpected result: %v, %v, %v", users, count, err)
+			}
+		},
+	)
+}
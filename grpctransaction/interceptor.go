@@ -0,0 +1,96 @@
+// Package grpctransaction run gRPC server handlers in a transaction of transaction.TransactionManager, with the
+// transaction policy declared per method.
+package grpctransaction
+
+import (
+	"context"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"gorm.io/gorm"
+	"strings"
+	"sync"
+)
+
+// Resolver return the options of the transaction of a method by its full name (/package.Service/Method), a
+// method without transaction policy (false) is handled out of transaction
+type Resolver func(fullMethod string) ([]transaction.TransactionOption, bool)
+
+// Methods resolve the transaction policy from policies keyed by full method name
+func Methods(policies map[string][]transaction.TransactionOption) Resolver {
+	return func(fullMethod string) ([]transaction.TransactionOption, bool) {
+		opts, ok := policies[fullMethod]
+		return opts, ok
+	}
+}
+
+// MethodOption resolve the transaction policy from the method option ext declared in the proto file, convert
+// turns the value of the option into the options of the transaction
+func MethodOption(ext protoreflect.ExtensionType, convert func(value interface{}) ([]transaction.TransactionOption, bool)) Resolver {
+	type policy struct {
+		opts []transaction.TransactionOption
+		ok   bool
+	}
+	var cache sync.Map
+	return func(fullMethod string) ([]transaction.TransactionOption, bool) {
+		if cached, ok := cache.Load(fullMethod); ok {
+			return cached.(policy).opts, cached.(policy).ok
+		}
+		var p policy
+		name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(fullMethod, "/"), "/", "."))
+		if desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name); err == nil {
+			if method, ok := desc.(protoreflect.MethodDescriptor); ok && method.Options() != nil &&
+				proto.HasExtension(method.Options(), ext) {
+				p.opts, p.ok = convert(proto.GetExtension(method.Options(), ext))
+			}
+		}
+		cache.Store(fullMethod, p)
+		return p.opts, p.ok
+	}
+}
+
+// UnaryServerInterceptor run unary handlers in a transaction with the options resolved by resolver, the
+// transaction rolls back when the handler returns a non-OK status
+func UnaryServerInterceptor(tm transaction.TransactionManager, resolver Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		opts, ok := resolver(info.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+		var resp interface{}
+		err := tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// StreamServerInterceptor run stream handlers in a transaction with the options resolved by resolver, the
+// transaction is bound to the context of the stream and rolls back when the handler returns a non-OK status
+func StreamServerInterceptor(tm transaction.TransactionManager, resolver Resolver) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		opts, ok := resolver(info.FullMethod)
+		if !ok {
+			return handler(srv, ss)
+		}
+		return tm.Transaction(ss.Context(), func(ctx context.Context, tx *gorm.DB) error {
+			return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		}, opts...)
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
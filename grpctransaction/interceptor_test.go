@@ -0,0 +1,264 @@
+package grpctransaction
+
+import (
+	"context"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"github.com/storm-blue/gorm-transaction/transactiontest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"gorm.io/gorm"
+	"net"
+	"sync"
+	"testing"
+)
+
+type Record struct {
+	ID   uint
+	Name string `gorm:"size:64"`
+}
+
+func (Record) TableName() string {
+	return "grpc_transaction_record"
+}
+
+func AssertRecords(db *gorm.DB, expected int64, t *testing.T) {
+	var count int64
+	db.Model(&Record{}).Count(&count)
+	if count != expected {
+		t.Errorf("there should be %v records, but %v", expected, count)
+	}
+	db.Delete(Record{}, "1=1")
+}
+
+// create a record named after the request with tm, the status code of the RPC is the one named by the request
+func create(ctx context.Context, tm transaction.TransactionManager, name string) error {
+	if _, ok := transaction.FromContext(ctx); !ok {
+		return status.Error(codes.FailedPrecondition, "not in transaction")
+	}
+	tm.GetDB(ctx).Create(&Record{Name: name})
+	if name == "fail" {
+		return status.Error(codes.InvalidArgument, "mock error")
+	}
+	return nil
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Records",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Create",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := &wrapperspb.StringValue{}
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/test.Records/Create"}
+			return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return req, create(ctx, srv.(transaction.TransactionManager), req.(*wrapperspb.StringValue).Value)
+			})
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "CreateStream",
+		ClientStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			for {
+				req := &wrapperspb.StringValue{}
+				if err := stream.RecvMsg(req); err != nil {
+					return stream.SendMsg(req)
+				}
+				if err := create(stream.Context(), srv.(transaction.TransactionManager), req.Value); err != nil {
+					return err
+				}
+			}
+		},
+	}},
+}
+
+// dial a server of the test service handling requests with tm
+func dial(t *testing.T, tm transaction.TransactionManager) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	resolver := Methods(map[string][]transaction.TransactionOption{
+		"/test.Records/Create":       {transaction.PropagationRequired},
+		"/test.Records/CreateStream": {transaction.PropagationRequired},
+	})
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(tm, resolver)),
+		grpc.StreamInterceptor(StreamServerInterceptor(tm, resolver)),
+	)
+	server.RegisterService(&serviceDesc, tm)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("client should be created: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	db := transactiontest.OpenSQLite(t, Record{})
+	conn := dial(t, transaction.NewTransactionManager(db))
+	ctx := context.Background()
+
+	err := conn.Invoke(ctx, "/test.Records/Create", wrapperspb.String("ok"), &wrapperspb.StringValue{})
+	if err != nil {
+		t.Errorf("error should be nil, but %v", err)
+	}
+	AssertRecords(db, 1, t)
+
+	err = conn.Invoke(ctx, "/test.Records/Create", wrapperspb.String("fail"), &wrapperspb.StringValue{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("status should be %v, but %v", codes.InvalidArgument, err)
+	}
+	AssertRecords(db, 0, t)
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	db := transactiontest.OpenSQLite(t, Record{})
+	conn := dial(t, transaction.NewTransactionManager(db))
+
+	send := func(names ...string) error {
+		stream, err := conn.NewStream(context.Background(), &serviceDesc.Streams[0], "/test.Records/CreateStream")
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err := stream.SendMsg(wrapperspb.String(name)); err != nil {
+				break
+			}
+		}
+		if err := stream.CloseSend(); err != nil {
+			return err
+		}
+		return stream.RecvMsg(&wrapperspb.StringValue{})
+	}
+
+	if err := send("ok", "ok"); err != nil {
+		t.Errorf("error should be nil, but %v", err)
+	}
+	AssertRecords(db, 2, t)
+
+	if err := send("ok", "fail"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("status should be %v, but %v", codes.InvalidArgument, err)
+	}
+	AssertRecords(db, 0, t)
+}
+
+var (
+	registerPolicy sync.Once
+	policyExt      protoreflect.ExtensionType
+)
+
+// registerPolicyFiles register the proto files of an orders service declaring its transaction policy with the
+// method option (test.tx_propagation), like the generated code of
+//
+//	extend google.protobuf.MethodOptions { string tx_propagation = 50001; }
+//	service Orders {
+//	  rpc Create(google.protobuf.StringValue) returns (google.protobuf.StringValue) { option (test.tx_propagation) = "REQUIRES_NEW"; }
+//	  rpc Get(google.protobuf.StringValue) returns (google.protobuf.StringValue);
+//	}
+func registerPolicyFiles(t *testing.T) protoreflect.ExtensionType {
+	registerPolicy.Do(func() {
+		register := func(file *descriptorpb.FileDescriptorProto) protoreflect.FileDescriptor {
+			fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+			if err == nil {
+				err = protoregistry.GlobalFiles.RegisterFile(fd)
+			}
+			if err != nil {
+				t.Fatalf("proto file should be registered: %v", err)
+			}
+			return fd
+		}
+
+		options := register(&descriptorpb.FileDescriptorProto{
+			Name:       proto.String("test/options.proto"),
+			Package:    proto.String("test"),
+			Dependency: []string{"google/protobuf/descriptor.proto"},
+			Extension: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("tx_propagation"),
+				Number:   proto.Int32(50001),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Extendee: proto.String(".google.protobuf.MethodOptions"),
+			}},
+			Syntax: proto.String("proto3"),
+		})
+		policyExt = dynamicpb.NewExtensionType(options.Extensions().Get(0))
+
+		createOptions := &descriptorpb.MethodOptions{}
+		proto.SetExtension(createOptions, policyExt, "REQUIRES_NEW")
+		register(&descriptorpb.FileDescriptorProto{
+			Name:       proto.String("test/orders.proto"),
+			Package:    proto.String("test"),
+			Dependency: []string{"google/protobuf/wrappers.proto", "test/options.proto"},
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name: proto.String("Orders"),
+				Method: []*descriptorpb.MethodDescriptorProto{{
+					Name:       proto.String("Create"),
+					InputType:  proto.String(".google.protobuf.StringValue"),
+					OutputType: proto.String(".google.protobuf.StringValue"),
+					Options:    createOptions,
+				}, {
+					Name:       proto.String("Get"),
+					InputType:  proto.String(".google.protobuf.StringValue"),
+					OutputType: proto.String(".google.protobuf.StringValue"),
+				}},
+			}},
+			Syntax: proto.String("proto3"),
+		})
+	})
+	if policyExt == nil {
+		t.FailNow()
+	}
+	return policyExt
+}
+
+func TestMethodOption(t *testing.T) {
+	var converted []interface{}
+	resolver := MethodOption(registerPolicyFiles(t), func(value interface{}) ([]transaction.TransactionOption, bool) {
+		converted = append(converted, value)
+		if value != "REQUIRES_NEW" {
+			return nil, false
+		}
+		return []transaction.TransactionOption{transaction.PropagationRequiresNew}, true
+	})
+
+	for i := 0; i < 2; i++ {
+		opts, ok := resolver("/test.Orders/Create")
+		if !ok || len(opts) != 1 {
+			t.Errorf("/test.Orders/Create should have the transaction policy of its method option")
+		}
+	}
+	if len(converted) != 1 || converted[0] != "REQUIRES_NEW" {
+		t.Errorf("the method option should be converted once, but %v", converted)
+	}
+
+	for _, fullMethod := range []string{"/test.Orders/Get", "/test.Orders/Delete", "/test.Records/Create"} {
+		if _, ok := resolver(fullMethod); ok {
+			t.Errorf("%v should have no transaction policy", fullMethod)
+		}
+	}
+	if len(converted) != 1 {
+		t.Errorf("methods without the option should not be converted, but %v", converted)
+	}
+}
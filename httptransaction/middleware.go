@@ -0,0 +1,120 @@
+// Package httptransaction run net/http handlers in a transaction of transaction.TransactionManager.
+package httptransaction
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+// errRollbackStatus roll back the transaction of a handler responding with a status above the threshold
+var errRollbackStatus = errors.New("rollback for response status")
+
+type config struct {
+	rollbackStatus int
+	txOptions      []transaction.TransactionOption
+	errorHandler   func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+type Option func(c *config)
+
+// WithRollbackStatus roll back the transaction when the handler responds with a status not below status,
+// http.StatusInternalServerError by default
+func WithRollbackStatus(status int) Option {
+	return func(c *config) {
+		c.rollbackStatus = status
+	}
+}
+
+// WithTransactionOptions set the options of the transaction, e.g. propagation or isolation level
+func WithTransactionOptions(opts ...transaction.TransactionOption) Option {
+	return func(c *config) {
+		c.txOptions = opts
+	}
+}
+
+// WithErrorHandler set the handler responding when the transaction fails to begin or commit, the buffered response
+// of the handler is discarded. It responds with http.StatusInternalServerError by default.
+func WithErrorHandler(handler func(w http.ResponseWriter, r *http.Request, err error)) Option {
+	return func(c *config) {
+		c.errorHandler = handler
+	}
+}
+
+// Middleware run each request in a transaction of tm bound to r.Context(). The response is buffered until the
+// transaction completes: it commits when the status is below the rollback status, otherwise it rolls back and the
+// response is sent as is. A panic rolls back the transaction and is propagated. As the response is buffered,
+// http.Flusher and http.Hijacker are not supported.
+func Middleware(tm transaction.TransactionManager, opts ...Option) func(http.Handler) http.Handler {
+	c := &config{
+		rollbackStatus: http.StatusInternalServerError,
+		errorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var buffer *responseBuffer
+			err := tm.Transaction(r.Context(), func(ctx context.Context, tx *gorm.DB) error {
+				// every attempt of a retried transaction starts with an empty response
+				buffer = newResponseBuffer()
+				next.ServeHTTP(buffer, r.WithContext(ctx))
+				if buffer.status >= c.rollbackStatus {
+					return errRollbackStatus
+				}
+				return nil
+			}, c.txOptions...)
+			if err != nil && !errors.Is(err, errRollbackStatus) {
+				c.errorHandler(w, r, err)
+				return
+			}
+			buffer.flush(w)
+		})
+	}
+}
+
+// responseBuffer hold the response of a handler until its transaction completes
+type responseBuffer struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{
+		header: make(http.Header),
+		status: http.StatusOK,
+	}
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader keep the first status like http.ResponseWriter does
+func (b *responseBuffer) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status = status
+		b.wroteHeader = true
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
+
+func (b *responseBuffer) flush(w http.ResponseWriter) {
+	for key, values := range b.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(b.status)
+	_, _ = b.body.WriteTo(w)
+}
@@ -0,0 +1,147 @@
+package httptransaction
+
+import (
+	"errors"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"github.com/storm-blue/gorm-transaction/transactiontest"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+type Record struct {
+	ID   uint
+	Name string `gorm:"size:64"`
+}
+
+func (Record) TableName() string {
+	return "http_transaction_record"
+}
+
+func AssertRecords(db *gorm.DB, expected int64, t *testing.T) {
+	var count int64
+	db.Model(&Record{}).Count(&count)
+	if count != expected {
+		t.Errorf("there should be %v records, but %v", expected, count)
+	}
+	db.Delete(Record{}, "1=1")
+}
+
+func serve(tm transaction.TransactionManager, handler http.HandlerFunc, opts ...Option) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	Middleware(tm, opts...)(handler).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", nil))
+	return recorder
+}
+
+func TestMiddleware(t *testing.T) {
+	db := transactiontest.OpenSQLite(t, Record{})
+	tm := transaction.NewTransactionManager(db)
+	create := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := transaction.FromContext(r.Context()); !ok {
+			t.Errorf("request context should be in transaction")
+		}
+		tm.GetDB(r.Context()).Create(&Record{Name: "record"})
+	}
+
+	recorder := serve(tm, func(w http.ResponseWriter, r *http.Request) {
+		create(w, r)
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created"))
+	})
+	if recorder.Code != http.StatusCreated || recorder.Body.String() != "created" || recorder.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("response of handler should be sent, but %v %v", recorder.Code, recorder.Body.String())
+	}
+	AssertRecords(db, 1, t)
+
+	recorder = serve(tm, func(w http.ResponseWriter, r *http.Request) {
+		create(w, r)
+		http.Error(w, "failed", http.StatusServiceUnavailable)
+	})
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("response of handler should be sent, but %v", recorder.Code)
+	}
+	AssertRecords(db, 0, t)
+
+	recorder = serve(tm, func(w http.ResponseWriter, r *http.Request) {
+		create(w, r)
+		w.WriteHeader(http.StatusBadRequest)
+	}, WithRollbackStatus(http.StatusBadRequest))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("response of handler should be sent, but %v", recorder.Code)
+	}
+	AssertRecords(db, 0, t)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("panic should be propagated")
+			}
+		}()
+		serve(tm, func(w http.ResponseWriter, r *http.Request) {
+			create(w, r)
+			panic("mock panic")
+		})
+	}()
+	AssertRecords(db, 0, t)
+}
+
+func TestMiddleware_CommitFailure(t *testing.T) {
+	db := transactiontest.OpenSQLite(t, Record{})
+	tm := transaction.NewTransactionManager(db)
+	var handled error
+	recorder := serve(tm, func(w http.ResponseWriter, r *http.Request) {
+		tm.GetDB(r.Context()).Create(&Record{Name: "record"})
+		_ = transaction.SetRollbackOnly(r.Context())
+		_, _ = w.Write([]byte("ok"))
+	}, WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		handled = err
+		w.WriteHeader(http.StatusConflict)
+	}))
+	if recorder.Code != http.StatusConflict || recorder.Body.String() != "" {
+		t.Errorf("buffered response should be discarded, but %v %v", recorder.Code, recorder.Body.String())
+	}
+	if !errors.Is(handled, transaction.ErrUnexpectedRollback) {
+		t.Errorf("error should be %v, but %v", transaction.ErrUnexpectedRollback, handled)
+	}
+	AssertRecords(db, 0, t)
+
+	recorder = serve(tm, func(w http.ResponseWriter, r *http.Request) {
+		_ = transaction.SetRollbackOnly(r.Context())
+	})
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("failed commit should respond %v, but %v", http.StatusInternalServerError, recorder.Code)
+	}
+}
+
+func TestMiddleware_Retry(t *testing.T) {
+	db := transactiontest.OpenSQLite(t, Record{})
+	tm := transaction.NewTransactionManager(db)
+	attempts := 0
+	recorder := serve(tm, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Add("X-Attempt", strconv.Itoa(attempts))
+		if attempts == 1 {
+			// the commit of the first attempt fails
+			_ = transaction.SetRollbackOnly(r.Context())
+			w.WriteHeader(http.StatusAccepted)
+		}
+		_, _ = w.Write([]byte("body;"))
+	}, WithTransactionOptions(transaction.WithRetry(transaction.RetryPolicy{
+		MaxAttempts: 2,
+		IsRetryable: func(err error) bool {
+			return errors.Is(err, transaction.ErrUnexpectedRollback)
+		},
+	})))
+	if attempts != 2 {
+		t.Fatalf("handler should run 2 times, but %v", attempts)
+	}
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "body;" {
+		t.Errorf("response should be the one of the last attempt, but %v %q", recorder.Code, recorder.Body.String())
+	}
+	if values := recorder.Header().Values("X-Attempt"); len(values) != 1 || values[0] != "2" {
+		t.Errorf("headers should be the ones of the last attempt, but %v", values)
+	}
+}
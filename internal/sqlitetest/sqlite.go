@@ -0,0 +1,23 @@
+// Package sqlitetest open the SQLite databases the tests of the module run against.
+package sqlitetest
+
+import (
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+)
+
+// Open open the database test.db in dir and migrate models. Writers wait for the lock of the database for a while
+// instead of failing at once, as the transactions of a test may write concurrently.
+func Open(dir string, models ...interface{}) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "test.db")+"?_busy_timeout=200"), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	if len(models) > 0 {
+		if err := db.AutoMigrate(models...); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
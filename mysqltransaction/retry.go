@@ -0,0 +1,25 @@
+// Package mysqltransaction classify the retryable errors of the MySQL driver, it's kept out of the transaction
+// package so that only the applications using MySQL link the driver.
+package mysqltransaction
+
+import (
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	transaction "github.com/storm-blue/gorm-transaction"
+)
+
+// IsRetryableError accept deadlock (1213) and lock wait timeout (1205)
+func IsRetryableError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+	return false
+}
+
+// DefaultRetryPolicy return transaction.DefaultRetryPolicy retrying the retryable errors of MySQL as well
+func DefaultRetryPolicy() transaction.RetryPolicy {
+	policy := transaction.DefaultRetryPolicy()
+	policy.IsRetryable = transaction.AnyRetryable(transaction.IsRetryableError, IsRetryableError)
+	return policy
+}
@@ -0,0 +1,36 @@
+package mysqltransaction
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"testing"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, true},
+		{fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1205}), true},
+		{&mysql.MySQLError{Number: 1062}, false},
+		{errors.New("mock error"), false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if IsRetryableError(c.err) != c.retryable {
+			t.Errorf("IsRetryableError(%v) should be %v", c.err, c.retryable)
+		}
+	}
+}
+
+func TestDefaultRetryPolicy(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	if !policy.IsRetryable(&mysql.MySQLError{Number: 1213}) {
+		t.Errorf("MySQL deadlock should be retryable")
+	}
+	if !policy.IsRetryable(errors.New("database is locked")) {
+		t.Errorf("errors retryable by default should still be retryable")
+	}
+}
@@ -0,0 +1,75 @@
+package transaction
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"time"
+)
+
+// CurrentTransactionName return the composed name of the transaction bound to ctx, empty if the transaction is
+// anonymous or ctx is not in transaction
+func CurrentTransactionName(ctx context.Context) string {
+	if txCtx, ok := fromContext(ctx); ok && txCtx.InTransaction() {
+		return txCtx.name
+	}
+	return ""
+}
+
+// callName return the name of a Transaction call with options, composed with the transaction bound to ctx
+func callName(ctx context.Context, options *transactionOptions) string {
+	return joinName(CurrentTransactionName(ctx), options.name)
+}
+
+func joinName(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	if name == "" {
+		return parent
+	}
+	return parent + "/" + name
+}
+
+// withTransactionLogger install transactionLogger to db if the transaction is named
+func withTransactionLogger(db *gorm.DB, name string) *gorm.DB {
+	if _, ok := db.Logger.(transactionLogger); name == "" || ok || db.Logger == nil {
+		return db
+	}
+	return db.Session(&gorm.Session{Logger: transactionLogger{Interface: db.Logger}})
+}
+
+// transactionLogger prefix the log output of gorm with the name of the transaction bound to the statement context
+type transactionLogger struct {
+	logger.Interface
+}
+
+func (l transactionLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return transactionLogger{Interface: l.Interface.LogMode(level)}
+}
+
+func (l transactionLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	l.Interface.Info(ctx, prefixName(ctx, msg), data...)
+}
+
+func (l transactionLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	l.Interface.Warn(ctx, prefixName(ctx, msg), data...)
+}
+
+func (l transactionLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	l.Interface.Error(ctx, prefixName(ctx, msg), data...)
+}
+
+func (l transactionLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	l.Interface.Trace(ctx, begin, func() (string, int64) {
+		sql, rowsAffected := fc()
+		return prefixName(ctx, sql), rowsAffected
+	}, err)
+}
+
+func prefixName(ctx context.Context, s string) string {
+	if name := CurrentTransactionName(ctx); name != "" {
+		return "[transaction:" + name + "] " + s
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+package transaction
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransactionManager_Transaction_Name(t *testing.T) {
+
+	DefaultTransactionTest("test-name-composed",
+		t,
+		func() {
+			ctx := context.Background()
+			if name := CurrentTransactionName(ctx); name != "" {
+				t.Errorf("name should be empty out of transaction, got %q", name)
+			}
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				if name := CurrentTransactionName(ctx); name != "PlaceOrder" {
+					t.Errorf("name should be PlaceOrder, got %q", name)
+				}
+				tx.Create(user1)
+
+				_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					if name := CurrentTransactionName(ctx); name != "PlaceOrder/ReserveStock" {
+						t.Errorf("name should be PlaceOrder/ReserveStock, got %q", name)
+					}
+					tx.Create(user2)
+					return nil
+				}, WithName("ReserveStock"))
+
+				_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					if name := CurrentTransactionName(ctx); name != "PlaceOrder" {
+						t.Errorf("anonymous call should keep the outer name, got %q", name)
+					}
+					tx.Create(user3)
+					return nil
+				}, PropagationNested)
+
+				if name := CurrentTransactionName(ctx); name != "PlaceOrder" {
+					t.Errorf("name should be restored to PlaceOrder, got %q", name)
+				}
+				return nil
+			}, WithName("PlaceOrder"))
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertExist(user2, t)
+			AssertExist(user3, t)
+		},
+	)
+
+	DefaultTransactionTest("test-name-not-supported",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					if name := CurrentTransactionName(ctx); name != "" {
+						t.Errorf("name should be empty out of transaction, got %q", name)
+					}
+					return nil
+				}, PropagationNotSupported, WithName("Audit"))
+				return nil
+			}, WithName("PlaceOrder"))
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+		},
+	)
+}
+
+type captureLogger struct {
+	logger.Interface
+	sqls []string
+}
+
+func (l *captureLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (l *captureLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	sql, _ := fc()
+	l.sqls = append(l.sqls, sql)
+}
+
+func TestTransactionManager_Transaction_NameLogger(t *testing.T) {
+	capture := &captureLogger{Interface: logger.Discard}
+	ntm := NewTransactionManager(db.Session(&gorm.Session{Logger: capture}))
+
+	DefaultTransactionTest("test-name-logger",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = ntm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				ntm.GetDB(ctx).Create(user1)
+				return ntm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					return tx.Create(user2).Error
+				}, WithName("ReserveStock"))
+			}, WithName("PlaceOrder"))
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertExist(user2, t)
+			var outer, inner bool
+			for _, sql := range capture.sqls {
+				outer = outer || strings.HasPrefix(sql, "[transaction:PlaceOrder] INSERT")
+				inner = inner || strings.HasPrefix(sql, "[transaction:PlaceOrder/ReserveStock] INSERT")
+			}
+			if !outer || !inner {
+				t.Errorf("statements should be prefixed with the transaction name, got %v", capture.sqls)
+			}
+		},
+	)
+}
@@ -0,0 +1,49 @@
+package transaction
+
+import "time"
+
+// TransactionInfo describe the Transaction call an observer is notified about
+type TransactionInfo struct {
+	// Name is the composed name of the transaction, see WithName
+	Name        string
+	Propagation Propagation
+}
+
+// MetricsObserver is notified by the TransactionManager at each lifecycle point of transactions,
+// it must be safe for concurrent use
+type MetricsObserver interface {
+	// OnCommit is invoked after a new transaction committed, duration is measured from Begin
+	OnCommit(info TransactionInfo, duration time.Duration)
+	// OnRollback is invoked after a new transaction rolled back, including rollbacks caused by panics
+	OnRollback(info TransactionInfo, duration time.Duration)
+	// OnPanic is invoked when bizFn of a new transaction panicked
+	OnPanic(info TransactionInfo)
+	// OnSavePointRollback is invoked after a PropagationNested savepoint rolled back
+	OnSavePointRollback(info TransactionInfo)
+	// OnRetry is invoked before a failed transaction is retried, see WithRetry
+	OnRetry(info TransactionInfo, attempt int, err error)
+}
+
+// NoopMetricsObserver ignore all notifications, it can be embedded to implement part of MetricsObserver
+type NoopMetricsObserver struct{}
+
+func (NoopMetricsObserver) OnCommit(TransactionInfo, time.Duration) {}
+
+func (NoopMetricsObserver) OnRollback(TransactionInfo, time.Duration) {}
+
+func (NoopMetricsObserver) OnPanic(TransactionInfo) {}
+
+func (NoopMetricsObserver) OnSavePointRollback(TransactionInfo) {}
+
+func (NoopMetricsObserver) OnRetry(TransactionInfo, int, error) {}
+
+func (m *transactionManager) observeCompletion(info TransactionInfo, duration time.Duration, panicked bool, committed bool) {
+	if panicked {
+		m.observer.OnPanic(info)
+	}
+	if committed {
+		m.observer.OnCommit(info, duration)
+	} else {
+		m.observer.OnRollback(info, duration)
+	}
+}
@@ -0,0 +1,175 @@
+package transaction
+
+import (
+	"database/sql"
+	"gorm.io/gorm"
+	"time"
+)
+
+// TransactionOption configure a single Transaction call, Propagation is a TransactionOption as well
+type TransactionOption interface {
+	apply(options *transactionOptions)
+}
+
+type transactionOptions struct {
+	propagation Propagation
+	isolation   sql.IsolationLevel
+	readOnly    bool
+	retry       *RetryPolicy
+	timeout     time.Duration
+	name        string
+
+	rollbackFor   []ErrorMatcher
+	noRollbackFor []ErrorMatcher
+}
+
+func newTransactionOptions(opts []TransactionOption) *transactionOptions {
+	options := &transactionOptions{
+		propagation: defaultPropagation(),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(options)
+		}
+	}
+	return options
+}
+
+func (o *transactionOptions) info(name string) TransactionInfo {
+	return TransactionInfo{
+		Name:        name,
+		Propagation: o.propagation,
+	}
+}
+
+func (o *transactionOptions) txOptions() *sql.TxOptions {
+	return &sql.TxOptions{
+		Isolation: o.isolation,
+		ReadOnly:  o.readOnly,
+	}
+}
+
+type transactionOptionFunc func(options *transactionOptions)
+
+func (f transactionOptionFunc) apply(options *transactionOptions) {
+	f(options)
+}
+
+func (p Propagation) apply(options *transactionOptions) {
+	options.propagation = p
+}
+
+// WithIsolationLevel set the isolation level used when a new transaction is begun
+func WithIsolationLevel(level sql.IsolationLevel) TransactionOption {
+	return transactionOptionFunc(func(options *transactionOptions) {
+		options.isolation = level
+	})
+}
+
+// WithReadOnly begin a read only transaction
+func WithReadOnly() TransactionOption {
+	return transactionOptionFunc(func(options *transactionOptions) {
+		options.readOnly = true
+	})
+}
+
+// WithRetry re-run bizFn in a fresh root transaction when it fails with an error accepted by policy.IsRetryable.
+// It only applies when the call begins a new transaction, a call participating in an existing transaction is
+// executed once and leaves retrying to the outer transaction.
+func WithRetry(policy RetryPolicy) TransactionOption {
+	return transactionOptionFunc(func(options *transactionOptions) {
+		options.retry = &policy
+	})
+}
+
+// WithTimeout bound the transaction with a deadline, a transaction exceeding it is rolled back and
+// ErrTransactionTimeout is returned. A participating call gets the tighter of its own and the outer deadline.
+func WithTimeout(timeout time.Duration) TransactionOption {
+	return transactionOptionFunc(func(options *transactionOptions) {
+		options.timeout = timeout
+	})
+}
+
+// WithName name the transaction after the business operation running in it, the name of a call inside another
+// transaction is composed with the outer one, e.g. PlaceOrder/ReserveStock, see CurrentTransactionName
+func WithName(name string) TransactionOption {
+	return transactionOptionFunc(func(options *transactionOptions) {
+		options.name = name
+	})
+}
+
+// ManagerOption configure the TransactionManager created by NewTransactionManager
+type ManagerOption func(m *transactionManager)
+
+// WithValidateExistingTransaction make participating calls (PropagationRequired, PropagationMandatory) return
+// ErrIsolationLevelNotSatisfied or ErrReadOnlyTransaction when the existing transaction can't satisfy their options,
+// by default the mismatch is ignored
+func WithValidateExistingTransaction(validate bool) ManagerOption {
+	return func(m *transactionManager) {
+		m.validateExisting = validate
+	}
+}
+
+// WithGlobalRollbackOnParticipationFailure decide whether a failed participating call (error or panic) marks the
+// existing transaction rollback only, it's enabled by default
+func WithGlobalRollbackOnParticipationFailure(enabled bool) ManagerOption {
+	return func(m *transactionManager) {
+		m.globalRollbackOnParticipationFailure = enabled
+	}
+}
+
+// WithPanicRecovery make Transaction recover the panics of bizFn and return them as *PanicError after rolling back,
+// by default the panic keeps unwinding once the transaction is rolled back
+func WithPanicRecovery(enabled bool) ManagerOption {
+	return func(m *transactionManager) {
+		m.recoverPanic = enabled
+	}
+}
+
+// WithDataSource name the datasource of the TransactionManager, transactions of managers with different datasources
+// are bound to a context independently, see Registry
+func WithDataSource(name string) ManagerOption {
+	return func(m *transactionManager) {
+		m.key = transactionContextKey{datasource: name}
+	}
+}
+
+// WithReplicas route reads to replicas: GetDB return a replica when ctx is not in transaction, and read only
+// transactions are begun on a replica. The tx of PropagationSupports, PropagationNotSupported and PropagationNever
+// calls out of transaction is still the primary, as their writes must survive. See WithReplicaPicker and
+// WithStickyPrimary
+func WithReplicas(replicas ...*gorm.DB) ManagerOption {
+	return func(m *transactionManager) {
+		m.replicas = replicas
+	}
+}
+
+// WithReplicaPicker set how the replica serving a read is chosen, RoundRobin by default
+func WithReplicaPicker(picker ReplicaPicker) ManagerOption {
+	return func(m *transactionManager) {
+		m.picker = picker
+	}
+}
+
+// WithStickyPrimary keep the reads of a StickySession on the primary for window after a read-write transaction of the
+// session commits, so that they can read their own writes despite replication lag. Contexts without a sticky session
+// always read from the replicas.
+func WithStickyPrimary(window time.Duration) ManagerOption {
+	return func(m *transactionManager) {
+		m.stickyWindow = window
+	}
+}
+
+// WithWatchdog track the open root transactions with watchdog
+func WithWatchdog(watchdog *Watchdog) ManagerOption {
+	return func(m *transactionManager) {
+		m.watchdog = watchdog
+	}
+}
+
+// WithMetricsObserver set the observer notified at each lifecycle point of transactions, see MetricsObserver
+func WithMetricsObserver(observer MetricsObserver) ManagerOption {
+	return func(m *transactionManager) {
+		m.observer = observer
+	}
+}
@@ -0,0 +1,106 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestTransactionManager_Transaction_Options(t *testing.T) {
+
+	validateTm := NewTransactionManager(db, WithValidateExistingTransaction(true))
+
+	DefaultTransactionTest("test-isolation-level-commit",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return nil
+			}, PropagationRequired, WithIsolationLevel(sql.LevelSerializable))
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+		},
+	)
+
+	var err error
+	DefaultTransactionTest("test-stricter-isolation-level-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			err = validateTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				return validateTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, PropagationRequired, WithIsolationLevel(sql.LevelSerializable))
+			}, WithIsolationLevel(sql.LevelReadCommitted))
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertNotExist(user2, t)
+			AssertErrorsIsEqual(err, ErrIsolationLevelNotSatisfied, t)
+		},
+	)
+
+	DefaultTransactionTest("test-weaker-isolation-level-commit",
+		t,
+		func() {
+			ctx := context.Background()
+			err = validateTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				return validateTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, PropagationMandatory, WithIsolationLevel(sql.LevelReadCommitted))
+			}, WithIsolationLevel(sql.LevelSerializable))
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertExist(user2, t)
+		},
+	)
+
+	DefaultTransactionTest("test-write-in-read-only-transaction",
+		t,
+		func() {
+			ctx := context.Background()
+			err = validateTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				return validateTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user1)
+					return nil
+				}, PropagationMandatory)
+			}, WithReadOnly())
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertErrorsIsEqual(err, ErrReadOnlyTransaction, t)
+		},
+	)
+
+	DefaultTransactionTest("test-stricter-isolation-level-ignored",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				return tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, WithIsolationLevel(sql.LevelSerializable))
+			})
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertExist(user2, t)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		},
+	)
+}
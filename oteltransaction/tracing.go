@@ -0,0 +1,213 @@
+// Package oteltransaction instrument a transaction.TransactionManager with OpenTelemetry tracing.
+package oteltransaction
+
+import (
+	"context"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const instrumentationName = "github.com/storm-blue/gorm-transaction/oteltransaction"
+
+const (
+	AttrPropagation = attribute.Key("db.transaction.propagation")
+	AttrNew         = attribute.Key("db.transaction.new")
+	AttrSavePoint   = attribute.Key("db.transaction.savepoint")
+	AttrDepth       = attribute.Key("db.transaction.depth")
+	AttrOutcome     = attribute.Key("db.transaction.outcome")
+)
+
+const (
+	OutcomeCommit   = "commit"
+	OutcomeRollback = "rollback"
+	OutcomePanic    = "panic"
+)
+
+type spanKey struct{}
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	spanName       string
+}
+
+type Option func(c *config)
+
+// WithTracerProvider set the TracerProvider, the global one is used by default
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = provider
+	}
+}
+
+// WithSpanName set the name of transaction spans, "gorm.transaction" by default
+func WithSpanName(name string) Option {
+	return func(c *config) {
+		c.spanName = name
+	}
+}
+
+type tracingTransactionManager struct {
+	transaction.TransactionManager
+	tracer   trace.Tracer
+	spanName string
+}
+
+// Wrap return a TransactionManager creating a span for every Transaction and Begin call of tm, the span of a Begin
+// call ends when its TxHandle commits or rolls back. The outcome of a call participating in an existing transaction
+// is the outcome of the call itself, the transaction may still roll back.
+func Wrap(tm transaction.TransactionManager, opts ...Option) transaction.TransactionManager {
+	c := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		spanName:       "gorm.transaction",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return &tracingTransactionManager{
+		TransactionManager: tm,
+		tracer:             c.tracerProvider.Tracer(instrumentationName),
+		spanName:           c.spanName,
+	}
+}
+
+// Unwrap return the wrapped TransactionManager
+func (m *tracingTransactionManager) Unwrap() transaction.TransactionManager {
+	return m.TransactionManager
+}
+
+func (m *tracingTransactionManager) Transaction(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, opts ...transaction.TransactionOption) error {
+	ctx, span, outerSavePoint := m.start(ctx, opts)
+	panicked := true
+	defer func() {
+		if panicked {
+			span.SetAttributes(AttrOutcome.String(OutcomePanic))
+			span.SetStatus(codes.Error, "panic")
+		}
+		span.End()
+	}()
+
+	err := m.TransactionManager.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		return bizFn(annotate(ctx, span, outerSavePoint), tx)
+	}, opts...)
+
+	// an error exempted by NoRollbackFor is recorded, but the outcome is a commit
+	if transaction.RollbackOn(err, opts...) {
+		span.SetAttributes(AttrOutcome.String(OutcomeRollback))
+	} else {
+		span.SetAttributes(AttrOutcome.String(OutcomeCommit))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	panicked = false
+	return err
+}
+
+func (m *tracingTransactionManager) Begin(ctx context.Context, opts ...transaction.TransactionOption) (context.Context, transaction.TxHandle, error) {
+	spanCtx, span, outerSavePoint := m.start(ctx, opts)
+	txCtx, h, err := m.TransactionManager.Begin(spanCtx, opts...)
+	if err != nil {
+		span.SetAttributes(AttrOutcome.String(OutcomeRollback))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return ctx, nil, err
+	}
+	return annotate(txCtx, span, outerSavePoint), &tracingTxHandle{TxHandle: h, span: span}, nil
+}
+
+// start start the span of a call with opts, outerSavePoint is the savepoint of the transaction ctx is in
+func (m *tracingTransactionManager) start(ctx context.Context, opts []transaction.TransactionOption) (_ context.Context, _ trace.Span, outerSavePoint string) {
+	propagation := propagationOf(opts)
+	outer, inTransaction := transaction.FromContext(ctx)
+	if inTransaction {
+		outerSavePoint = outer.SavePoint()
+	}
+
+	startOpts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(AttrPropagation.String(propagation.String())),
+	}
+	if propagation == transaction.PropagationRequiresNew && inTransaction {
+		// link to the span of the transaction suspended by this one
+		if suspended, ok := ctx.Value(spanKey{}).(trace.Span); ok {
+			startOpts = append(startOpts, trace.WithLinks(trace.Link{SpanContext: suspended.SpanContext()}))
+		}
+	}
+	ctx, span := m.tracer.Start(ctx, m.spanName, startOpts...)
+	return ctx, span, outerSavePoint
+}
+
+// annotate set the attributes of the transaction ctx of the call is in to span
+func annotate(ctx context.Context, span trace.Span, outerSavePoint string) context.Context {
+	txCtx, ok := transaction.FromContext(ctx)
+	if !ok {
+		span.SetAttributes(AttrNew.Bool(false), AttrSavePoint.Bool(false))
+		return ctx
+	}
+	span.SetAttributes(
+		AttrNew.Bool(txCtx.IsRoot()),
+		AttrSavePoint.Bool(txCtx.SavePoint() != "" && txCtx.SavePoint() != outerSavePoint),
+		AttrDepth.Int(txCtx.Depth()),
+	)
+	if txCtx.IsRoot() {
+		ctx = context.WithValue(ctx, spanKey{}, span)
+	}
+	return ctx
+}
+
+// tracingTxHandle end the span of a Begin call once the call is completed
+type tracingTxHandle struct {
+	transaction.TxHandle
+	span trace.Span
+}
+
+func (h *tracingTxHandle) Commit() error {
+	return h.complete(h.TxHandle.Commit)
+}
+
+func (h *tracingTxHandle) Rollback() error {
+	return h.complete(h.TxHandle.Rollback)
+}
+
+func (h *tracingTxHandle) complete(fn func() error) (err error) {
+	if h.Status() != transaction.TxStatusActive {
+		// the span has already ended
+		return fn()
+	}
+	panicked := true
+	defer func() {
+		switch {
+		case panicked:
+			h.span.SetAttributes(AttrOutcome.String(OutcomePanic))
+			h.span.SetStatus(codes.Error, "panic")
+		case h.Status() == transaction.TxStatusCommitted:
+			h.span.SetAttributes(AttrOutcome.String(OutcomeCommit))
+		default:
+			h.span.SetAttributes(AttrOutcome.String(OutcomeRollback))
+		}
+		if err != nil {
+			h.span.RecordError(err)
+			h.span.SetStatus(codes.Error, err.Error())
+		}
+		h.span.End()
+	}()
+	err = fn()
+	panicked = false
+	return err
+}
+
+func propagationOf(opts []transaction.TransactionOption) transaction.Propagation {
+	propagation := transaction.PropagationRequired
+	for _, opt := range opts {
+		if p, ok := opt.(transaction.Propagation); ok {
+			propagation = p
+		}
+	}
+	return propagation
+}
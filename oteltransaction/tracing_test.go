@@ -0,0 +1,172 @@
+package oteltransaction
+
+import (
+	"context"
+	"errors"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"github.com/storm-blue/gorm-transaction/transactiontest"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/gorm"
+	"testing"
+)
+
+var mockErr = errors.New("mock error")
+
+func newTracingTransactionManager(t *testing.T) (transaction.TransactionManager, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tm := transaction.NewTransactionManager(transactiontest.OpenSQLite(t))
+	return Wrap(tm, WithTracerProvider(provider)), exporter
+}
+
+func attributeOf(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func AssertAttribute(span tracetest.SpanStub, key attribute.Key, expected attribute.Value, t *testing.T) {
+	if actual := attributeOf(span, key); actual != expected {
+		t.Errorf("attribute %v of span should be %v, but %v", key, expected.Emit(), actual.Emit())
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tm, exporter := newTracingTransactionManager(t)
+
+	ctx := context.Background()
+	_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			return nil
+		}, transaction.PropagationRequired)
+
+		_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			return mockErr
+		}, transaction.PropagationNested)
+
+		_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			return nil
+		}, transaction.PropagationRequiresNew)
+		return nil
+	})
+
+	spans := exporter.GetSpans()
+	if len(spans) != 4 {
+		t.Fatalf("there should be 4 spans, but %v", len(spans))
+	}
+	required, nested, requiresNew, root := spans[0], spans[1], spans[2], spans[3]
+
+	AssertAttribute(root, AttrPropagation, attribute.StringValue("REQUIRED"), t)
+	AssertAttribute(root, AttrNew, attribute.BoolValue(true), t)
+	AssertAttribute(root, AttrDepth, attribute.IntValue(0), t)
+	AssertAttribute(root, AttrOutcome, attribute.StringValue(OutcomeCommit), t)
+
+	AssertAttribute(required, AttrNew, attribute.BoolValue(false), t)
+	AssertAttribute(required, AttrSavePoint, attribute.BoolValue(false), t)
+	AssertAttribute(required, AttrDepth, attribute.IntValue(1), t)
+	if required.Parent.SpanID() != root.SpanContext.SpanID() {
+		t.Errorf("span of participating call should be child of root span")
+	}
+
+	AssertAttribute(nested, AttrPropagation, attribute.StringValue("NESTED"), t)
+	AssertAttribute(nested, AttrSavePoint, attribute.BoolValue(true), t)
+	AssertAttribute(nested, AttrOutcome, attribute.StringValue(OutcomeRollback), t)
+	if len(nested.Events) == 0 || nested.Events[0].Name != "exception" {
+		t.Errorf("error should be recorded")
+	}
+
+	AssertAttribute(requiresNew, AttrNew, attribute.BoolValue(true), t)
+	AssertAttribute(requiresNew, AttrDepth, attribute.IntValue(0), t)
+	if len(requiresNew.Links) != 1 || requiresNew.Links[0].SpanContext.SpanID() != root.SpanContext.SpanID() {
+		t.Errorf("span of requires new call should link to the suspended transaction")
+	}
+}
+
+func TestWrap_Panic(t *testing.T) {
+	tm, exporter := newTracingTransactionManager(t)
+
+	func() {
+		defer func() { recover() }()
+		_ = tm.Transaction(context.Background(), func(ctx context.Context, tx *gorm.DB) error {
+			panic("mock panic")
+		})
+	}()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("there should be 1 span, but %v", len(spans))
+	}
+	AssertAttribute(spans[0], AttrOutcome, attribute.StringValue(OutcomePanic), t)
+}
+
+func TestWrap_NoRollbackFor(t *testing.T) {
+	tm, exporter := newTracingTransactionManager(t)
+
+	err := tm.Transaction(context.Background(), func(ctx context.Context, tx *gorm.DB) error {
+		return mockErr
+	}, transaction.NoRollbackFor(transaction.ErrorIs(mockErr)))
+	if err != mockErr {
+		t.Errorf("error should be %v, but %v", mockErr, err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("there should be 1 span, but %v", len(spans))
+	}
+	AssertAttribute(spans[0], AttrOutcome, attribute.StringValue(OutcomeCommit), t)
+	if len(spans[0].Events) == 0 {
+		t.Errorf("error should be recorded")
+	}
+}
+
+func TestWrap_Begin(t *testing.T) {
+	tm, exporter := newTracingTransactionManager(t)
+
+	ctx, root, err := tm.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("error should be nil, but %v", err)
+	}
+	_, nested, err := tm.Begin(ctx, transaction.PropagationNested)
+	if err != nil {
+		t.Fatalf("error should be nil, but %v", err)
+	}
+	if len(exporter.GetSpans()) != 0 {
+		t.Errorf("spans should not end before the calls are completed")
+	}
+	_ = nested.Rollback()
+	_ = root.Commit()
+	if err := root.Commit(); !errors.Is(err, transaction.ErrTransactionCompleted) {
+		t.Errorf("error should be %v, but %v", transaction.ErrTransactionCompleted, err)
+	}
+
+	_, _, err = tm.Begin(context.Background(), transaction.PropagationMandatory)
+	if !errors.Is(err, transaction.ErrMandatoryPropWithoutTransaction) {
+		t.Errorf("error should be %v, but %v", transaction.ErrMandatoryPropWithoutTransaction, err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("there should be 3 spans, but %v", len(spans))
+	}
+	nestedSpan, rootSpan, mandatory := spans[0], spans[1], spans[2]
+
+	AssertAttribute(rootSpan, AttrNew, attribute.BoolValue(true), t)
+	AssertAttribute(rootSpan, AttrOutcome, attribute.StringValue(OutcomeCommit), t)
+
+	AssertAttribute(nestedSpan, AttrPropagation, attribute.StringValue("NESTED"), t)
+	AssertAttribute(nestedSpan, AttrSavePoint, attribute.BoolValue(true), t)
+	AssertAttribute(nestedSpan, AttrOutcome, attribute.StringValue(OutcomeRollback), t)
+	if nestedSpan.Parent.SpanID() != rootSpan.SpanContext.SpanID() {
+		t.Errorf("span of nested call should be child of root span")
+	}
+
+	AssertAttribute(mandatory, AttrOutcome, attribute.StringValue(OutcomeRollback), t)
+	if len(mandatory.Events) == 0 || mandatory.Events[0].Name != "exception" {
+		t.Errorf("error should be recorded")
+	}
+}
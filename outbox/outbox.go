@@ -0,0 +1,72 @@
+// Package outbox implement the transactional outbox pattern on top of transaction.TransactionManager: events are
+// written to an outbox table in the transaction of the business data, and a Relay publishes them afterwards.
+package outbox
+
+import (
+	"context"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"gorm.io/gorm"
+	"time"
+)
+
+const DefaultTable = "outbox"
+
+// Event is a domain event to publish
+type Event struct {
+	// AggregateKey order the events, events of the same key are published in the order they are added
+	AggregateKey string
+	Topic        string
+	Payload      []byte
+}
+
+// Message is an event stored in the outbox table
+type Message struct {
+	ID           uint64 `gorm:"primaryKey"`
+	AggregateKey string `gorm:"size:255;index"`
+	Topic        string `gorm:"size:255"`
+	Payload      []byte
+	CreatedAt    time.Time
+	SentAt       *time.Time `gorm:"index"`
+}
+
+type Outbox struct {
+	tm    transaction.TransactionManager
+	table string
+}
+
+type Option func(o *Outbox)
+
+// WithTable set the name of the outbox table, DefaultTable by default
+func WithTable(table string) Option {
+	return func(o *Outbox) {
+		o.table = table
+	}
+}
+
+func New(tm transaction.TransactionManager, opts ...Option) *Outbox {
+	o := &Outbox{
+		tm:    tm,
+		table: DefaultTable,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Migrate create or update the outbox table, it always runs on the origin database rather than a replica
+func (o *Outbox) Migrate(ctx context.Context) error {
+	return o.tm.GetOriginDB().WithContext(ctx).Table(o.table).AutoMigrate(&Message{})
+}
+
+// Add write event to the outbox table in the transaction of ctx, it returns
+// transaction.ErrMandatoryPropWithoutTransaction if ctx is not in transaction
+func (o *Outbox) Add(ctx context.Context, event Event) error {
+	return o.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Table(o.table).Create(&Message{
+			AggregateKey: event.AggregateKey,
+			Topic:        event.Topic,
+			Payload:      event.Payload,
+		}).Error
+	}, transaction.PropagationMandatory)
+}
@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"github.com/storm-blue/gorm-transaction/transactiontest"
+	"gorm.io/gorm"
+	"testing"
+)
+
+var mockErr = errors.New("mock error")
+
+// newOutbox return an outbox of a new database and the TransactionManager it writes in
+func newOutbox(t *testing.T) (*Outbox, transaction.TransactionManager) {
+	tm := transaction.NewTransactionManager(transactiontest.OpenSQLite(t))
+	o := New(tm, WithTable("outbox_test"))
+	if err := o.Migrate(context.Background()); err != nil {
+		t.Fatalf("outbox table should be migrated: %v", err)
+	}
+	return o, tm
+}
+
+func AssertPublished(publisher *MemoryPublisher, expected []string, t *testing.T) {
+	var actual []string
+	for _, msg := range publisher.Messages() {
+		actual = append(actual, string(msg.Payload))
+	}
+	if len(actual) != len(expected) {
+		t.Errorf("published messages should be %v, but %v", expected, actual)
+		return
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("published messages should be %v, but %v", expected, actual)
+			return
+		}
+	}
+}
+
+func TestOutbox_Add(t *testing.T) {
+	o, tm := newOutbox(t)
+	ctx := context.Background()
+
+	if err := o.Add(ctx, Event{Payload: []byte("e0")}); !errors.Is(err, transaction.ErrMandatoryPropWithoutTransaction) {
+		t.Errorf("error should be %v, but %v", transaction.ErrMandatoryPropWithoutTransaction, err)
+	}
+	_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		_ = o.Add(ctx, Event{Topic: "order", Payload: []byte("e1")})
+		return mockErr
+	})
+	_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		return o.Add(ctx, Event{Topic: "order", Payload: []byte("e2")})
+	})
+
+	publisher := &MemoryPublisher{}
+	sent, err := o.Relay(publisher).RelayOnce(ctx)
+	if sent != 1 || err != nil {
+		t.Errorf("1 message should be sent, but %v: %v", sent, err)
+	}
+	AssertPublished(publisher, []string{"e2"}, t)
+
+	sent, err = o.Relay(publisher).RelayOnce(ctx)
+	if sent != 0 || err != nil {
+		t.Errorf("sent messages should not be sent again, but %v: %v", sent, err)
+	}
+}
+
+func TestRelay_Ordering(t *testing.T) {
+	o, tm := newOutbox(t)
+	ctx := context.Background()
+
+	_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		for _, event := range []Event{
+			{AggregateKey: "a", Payload: []byte("a1")},
+			{AggregateKey: "b", Payload: []byte("b1")},
+			{AggregateKey: "a", Payload: []byte("a2")},
+			{AggregateKey: "b", Payload: []byte("b2")},
+		} {
+			if err := o.Add(ctx, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	publisher := &MemoryPublisher{
+		Fail: func(msg Message) error {
+			if string(msg.Payload) == "a1" {
+				return mockErr
+			}
+			return nil
+		},
+	}
+	relay := o.Relay(publisher)
+	sent, err := relay.RelayOnce(ctx)
+	if sent != 2 || !errors.Is(err, mockErr) {
+		t.Errorf("2 messages should be sent with error %v, but %v: %v", mockErr, sent, err)
+	}
+	AssertPublished(publisher, []string{"b1", "b2"}, t)
+
+	publisher.Fail = nil
+	sent, err = relay.RelayOnce(ctx)
+	if sent != 2 || err != nil {
+		t.Errorf("2 messages should be sent, but %v: %v", sent, err)
+	}
+	AssertPublished(publisher, []string{"b1", "b2", "a1", "a2"}, t)
+}
+
+func TestRelay_Run(t *testing.T) {
+	o, tm := newOutbox(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		_ = o.Add(ctx, Event{Payload: []byte("e1")})
+		return o.Add(ctx, Event{Payload: []byte("e2")})
+	})
+
+	publisher := &MemoryPublisher{
+		Fail: func(msg Message) error {
+			if string(msg.Payload) == "e2" {
+				cancel()
+			}
+			return nil
+		},
+	}
+	if err := o.Relay(publisher, WithBatchSize(1)).Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("error should be %v, but %v", context.Canceled, err)
+	}
+	AssertPublished(publisher, []string{"e1", "e2"}, t)
+}
@@ -0,0 +1,192 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sync"
+	"time"
+)
+
+// Publisher deliver a message to the message broker, e.g. Kafka
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// Relay publish the unsent messages of an Outbox. Messages are delivered at least once: a message is marked sent
+// after it's published, if marking fails it's published again by the next poll.
+type Relay struct {
+	outbox    *Outbox
+	publisher Publisher
+	batchSize int
+	interval  time.Duration
+	onError   func(err error)
+}
+
+type RelayOption func(r *Relay)
+
+// WithBatchSize set the maximum number of messages published per poll, 100 by default
+func WithBatchSize(size int) RelayOption {
+	return func(r *Relay) {
+		r.batchSize = size
+	}
+}
+
+// WithPollInterval set the interval between two polls when the outbox is drained, 1 second by default
+func WithPollInterval(interval time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.interval = interval
+	}
+}
+
+// WithErrorHandler set the handler of errors occurring in Run, they are ignored by default
+func WithErrorHandler(handler func(err error)) RelayOption {
+	return func(r *Relay) {
+		r.onError = handler
+	}
+}
+
+// Relay create a Relay publishing the messages of o with publisher
+func (o *Outbox) Relay(publisher Publisher, opts ...RelayOption) *Relay {
+	r := &Relay{
+		outbox:    o,
+		publisher: publisher,
+		batchSize: 100,
+		interval:  time.Second,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run poll the outbox until ctx is done
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		sent, err := r.RelayOnce(ctx)
+		if err != nil && r.onError != nil {
+			r.onError(err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// keep polling without waiting while there is a backlog
+		if err == nil && sent == r.batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publish a batch of unsent messages and return the number of messages sent. Messages are locked with
+// SELECT ... FOR UPDATE SKIP LOCKED so that several relays can run concurrently. Messages of an aggregate key are
+// published in order: once a message fails, or an earlier message of its key is locked by another relay, the rest
+// of the key is left to the next poll.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	var sent int
+	var errs []error
+	err := r.outbox.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		var batch []Message
+		err := tx.Table(r.outbox.table).
+			Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: clause.LockingOptionsSkipLocked}).
+			Where("sent_at IS NULL").Order("id").Limit(r.batchSize).Find(&batch).Error
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		heads, err := r.heads(tx, batch)
+		if err != nil {
+			return err
+		}
+
+		blocked := make(map[string]bool)
+		var sentIDs []uint64
+		for _, msg := range batch {
+			key := msg.AggregateKey
+			if blocked[key] {
+				continue
+			}
+			if head, ok := heads[key]; ok && head < msg.ID {
+				// an earlier message of the key is unsent
+				blocked[key] = true
+				continue
+			}
+			if err := r.publisher.Publish(ctx, msg); err != nil {
+				errs = append(errs, err)
+				if key != "" {
+					blocked[key] = true
+				}
+				continue
+			}
+			sentIDs = append(sentIDs, msg.ID)
+			delete(heads, key)
+		}
+		if len(sentIDs) == 0 {
+			return nil
+		}
+		err = tx.Table(r.outbox.table).Where("id IN ?", sentIDs).Update("sent_at", time.Now()).Error
+		if err == nil {
+			sent = len(sentIDs)
+		}
+		return err
+	}, transaction.WithName("outbox.relay"))
+	return sent, errors.Join(append(errs, err)...)
+}
+
+// heads return the id of the earliest unsent message of each aggregate key in batch
+func (r *Relay) heads(tx *gorm.DB, batch []Message) (map[string]uint64, error) {
+	var keys []string
+	for _, msg := range batch {
+		if msg.AggregateKey != "" {
+			keys = append(keys, msg.AggregateKey)
+		}
+	}
+	heads := make(map[string]uint64)
+	if len(keys) == 0 {
+		return heads, nil
+	}
+	var rows []struct {
+		AggregateKey string
+		ID           uint64
+	}
+	err := tx.Table(r.outbox.table).Select("aggregate_key, MIN(id) AS id").
+		Where("sent_at IS NULL AND aggregate_key IN ?", keys).Group("aggregate_key").Scan(&rows).Error
+	for _, row := range rows {
+		heads[row.AggregateKey] = row.ID
+	}
+	return heads, err
+}
+
+// MemoryPublisher is an in-memory Publisher for tests
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	messages []Message
+	// Fail, if set, is consulted before publishing msg, a non-nil error fails the publishing
+	Fail func(msg Message) error
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Fail != nil {
+		if err := p.Fail(msg); err != nil {
+			return err
+		}
+	}
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+// Messages return the published messages in order
+func (p *MemoryPublisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.messages...)
+}
@@ -0,0 +1,40 @@
+package transaction
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned by Transaction instead of re-panicking when bizFn panics and WithPanicRecovery is enabled,
+// the transaction has been rolled back. A panic of an AfterCommit or AfterCompletion synchronization is returned as
+// well, the transaction has committed in that case, see Committed.
+type PanicError struct {
+	// Value is the value recovered from the panic
+	Value interface{}
+	// Stack is the stack trace of the goroutine at the time of the panic
+	Stack []byte
+	// Committed report whether the transaction committed before the panic, i.e. a synchronization panicked after
+	// the commit
+	Committed bool
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("transaction panicked: %v", e.Value)
+}
+
+// Unwrap return the recovered value when it's an error, e.g. a runtime.Error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverPanic turn a panic into a *PanicError assigned to err, it must be deferred directly
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = newPanicError(r, false)
+	}
+}
+
+func newPanicError(value interface{}, committed bool) *PanicError {
+	return &PanicError{Value: value, Stack: debug.Stack(), Committed: committed}
+}
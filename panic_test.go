@@ -0,0 +1,109 @@
+package transaction
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"runtime"
+	"testing"
+)
+
+func TestTransactionManager_Transaction_PanicRecovery(t *testing.T) {
+	recoveringTm := NewTransactionManager(db, WithPanicRecovery(true))
+
+	var err error
+	DefaultTransactionTest("test-recover-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			err = recoveringTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				mockPanic()
+				return nil
+			}, PropagationRequired)
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			var panicErr *PanicError
+			if !errors.As(err, &panicErr) {
+				t.Fatalf("error %v should be a *PanicError", err)
+			}
+			if panicErr.Value != "mock panic" {
+				t.Errorf("recovered value should be %v, but %v", "mock panic", panicErr.Value)
+			}
+			if !bytes.Contains(panicErr.Stack, []byte("panic_test.go")) {
+				t.Errorf("stack should contain bizFn:\n%s", panicErr.Stack)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-recover-participation-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			err = recoveringTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				innerErr := recoveringTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					mockPanic()
+					return nil
+				}, PropagationNested)
+
+				var panicErr *PanicError
+				if !errors.As(innerErr, &panicErr) {
+					t.Errorf("error %v should be a *PanicError", innerErr)
+				}
+				return nil
+			}, PropagationRequired)
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertNotExist(user2, t)
+			if err != nil {
+				t.Errorf("error should be nil, but %v", err)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-recover-runtime-error",
+		t,
+		func() {
+			ctx := context.Background()
+			err = recoveringTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				var users []*User
+				tx.Create(users[0])
+				return nil
+			}, PropagationRequired)
+		},
+		func(t *testing.T) {
+			var runtimeErr runtime.Error
+			if !errors.As(err, &runtimeErr) {
+				t.Errorf("error %v should unwrap to a runtime.Error", err)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-re-panic-by-default",
+		t,
+		func() {
+			ctx := context.Background()
+			func() {
+				defer func() {
+					if r := recover(); r == nil {
+						t.Errorf("panic should keep unwinding")
+					}
+				}()
+				_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user1)
+					mockPanic()
+					return nil
+				}, PropagationRequired)
+			}()
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+		},
+	)
+}
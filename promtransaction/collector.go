@@ -0,0 +1,121 @@
+// Package promtransaction collect Prometheus metrics of transactions through transaction.MetricsObserver.
+package promtransaction
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"time"
+)
+
+var labels = []string{"name", "propagation"}
+
+type config struct {
+	namespace string
+	buckets   []float64
+}
+
+type Option func(c *config)
+
+// WithNamespace set the namespace of metrics, "gorm" by default
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithBuckets set the buckets of the transaction duration histogram, prometheus.DefBuckets by default
+func WithBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+// Collector is both a transaction.MetricsObserver and a prometheus.Collector, pass it to
+// transaction.WithMetricsObserver and register it to a prometheus.Registerer
+type Collector struct {
+	commits            *prometheus.CounterVec
+	rollbacks          *prometheus.CounterVec
+	panics             *prometheus.CounterVec
+	savepointRollbacks *prometheus.CounterVec
+	retries            *prometheus.CounterVec
+	duration           *prometheus.HistogramVec
+}
+
+func NewCollector(opts ...Option) *Collector {
+	c := &config{
+		namespace: "gorm",
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: c.namespace,
+			Subsystem: "transaction",
+			Name:      name,
+			Help:      help,
+		}, labels)
+	}
+	return &Collector{
+		commits:            counter("commits_total", "Number of committed transactions."),
+		rollbacks:          counter("rollbacks_total", "Number of rolled back transactions."),
+		panics:             counter("panics_total", "Number of transactions rolled back because of panics."),
+		savepointRollbacks: counter("savepoint_rollbacks_total", "Number of savepoints rolled back."),
+		retries:            counter("retries_total", "Number of retried transactions."),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: c.namespace,
+			Subsystem: "transaction",
+			Name:      "duration_seconds",
+			Help:      "Duration of transactions from begin to commit or rollback.",
+			Buckets:   c.buckets,
+		}, labels),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.commits.Describe(ch)
+	c.rollbacks.Describe(ch)
+	c.panics.Describe(ch)
+	c.savepointRollbacks.Describe(ch)
+	c.retries.Describe(ch)
+	c.duration.Describe(ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.commits.Collect(ch)
+	c.rollbacks.Collect(ch)
+	c.panics.Collect(ch)
+	c.savepointRollbacks.Collect(ch)
+	c.retries.Collect(ch)
+	c.duration.Collect(ch)
+}
+
+func (c *Collector) OnCommit(info transaction.TransactionInfo, duration time.Duration) {
+	c.commits.WithLabelValues(labelValues(info)...).Inc()
+	c.duration.WithLabelValues(labelValues(info)...).Observe(duration.Seconds())
+}
+
+func (c *Collector) OnRollback(info transaction.TransactionInfo, duration time.Duration) {
+	c.rollbacks.WithLabelValues(labelValues(info)...).Inc()
+	c.duration.WithLabelValues(labelValues(info)...).Observe(duration.Seconds())
+}
+
+func (c *Collector) OnPanic(info transaction.TransactionInfo) {
+	c.panics.WithLabelValues(labelValues(info)...).Inc()
+}
+
+func (c *Collector) OnSavePointRollback(info transaction.TransactionInfo) {
+	c.savepointRollbacks.WithLabelValues(labelValues(info)...).Inc()
+}
+
+func (c *Collector) OnRetry(info transaction.TransactionInfo, attempt int, err error) {
+	c.retries.WithLabelValues(labelValues(info)...).Inc()
+}
+
+func labelValues(info transaction.TransactionInfo) []string {
+	return []string{info.Name, info.Propagation.String()}
+}
+
+var _ transaction.MetricsObserver = (*Collector)(nil)
+var _ prometheus.Collector = (*Collector)(nil)
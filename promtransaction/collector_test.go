@@ -0,0 +1,68 @@
+package promtransaction
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"github.com/storm-blue/gorm-transaction/transactiontest"
+	"gorm.io/gorm"
+	"testing"
+)
+
+var mockErr = errors.New("mock error")
+
+func AssertCounter(counter *prometheus.CounterVec, name string, propagation transaction.Propagation, expected float64, t *testing.T) {
+	if actual := testutil.ToFloat64(counter.WithLabelValues(name, propagation.String())); actual != expected {
+		t.Errorf("counter of %v/%v should be %v, but %v", name, propagation, expected, actual)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	collector := NewCollector()
+	db := transactiontest.OpenSQLite(t)
+	tm := transaction.NewTransactionManager(db, transaction.WithMetricsObserver(collector))
+	ctx := context.Background()
+
+	_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			return mockErr
+		}, transaction.PropagationNested, transaction.WithName("inner"))
+		return nil
+	}, transaction.WithName("outer"))
+
+	_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		return mockErr
+	}, transaction.PropagationRequiresNew, transaction.WithName("outer"))
+
+	func() {
+		defer func() { recover() }()
+		_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			panic("mock panic")
+		}, transaction.WithName("outer"))
+	}()
+
+	policy := transaction.DefaultRetryPolicy()
+	policy.InitialBackoff = 0
+	policy.IsRetryable = func(err error) bool { return errors.Is(err, mockErr) }
+	_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		return mockErr
+	}, transaction.WithName("retry"), transaction.WithRetry(policy))
+
+	AssertCounter(collector.commits, "outer", transaction.PropagationRequired, 1, t)
+	AssertCounter(collector.rollbacks, "outer", transaction.PropagationRequired, 1, t)
+	AssertCounter(collector.rollbacks, "outer", transaction.PropagationRequiresNew, 1, t)
+	AssertCounter(collector.panics, "outer", transaction.PropagationRequired, 1, t)
+	AssertCounter(collector.savepointRollbacks, "outer/inner", transaction.PropagationNested, 1, t)
+	AssertCounter(collector.retries, "retry", transaction.PropagationRequired, 2, t)
+	AssertCounter(collector.rollbacks, "retry", transaction.PropagationRequired, 3, t)
+
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Errorf("collector should be registered: %v", err)
+	}
+	if count := testutil.CollectAndCount(collector, "gorm_transaction_duration_seconds"); count != 3 {
+		t.Errorf("there should be 3 duration histograms, but %v", count)
+	}
+}
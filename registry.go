@@ -0,0 +1,64 @@
+package transaction
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"sort"
+	"sync"
+)
+
+var ErrDataSourceNotRegistered = errors.New("datasource is not registered")
+
+// Registry hold a TransactionManager per datasource. Each manager binds its transactions to a context under its own
+// datasource, so transactions on different datasources can be opened independently within the same context chain
+// and GetDB resolves the transaction of its own datasource.
+type Registry struct {
+	mu       sync.RWMutex
+	managers map[string]TransactionManager
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		managers: make(map[string]TransactionManager),
+	}
+}
+
+// Register create the TransactionManager of datasource name with db, it replaces the one registered before
+func (r *Registry) Register(name string, db *gorm.DB, opts ...ManagerOption) TransactionManager {
+	tm := NewTransactionManager(db, append(opts, WithDataSource(name))...)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.managers[name] = tm
+	return tm
+}
+
+// Get return the TransactionManager of datasource name, ErrDataSourceNotRegistered if there isn't
+func (r *Registry) Get(name string) (TransactionManager, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if tm, ok := r.managers[name]; ok {
+		return tm, nil
+	}
+	return nil, ErrDataSourceNotRegistered
+}
+
+// MustGet is like Get but panics if the datasource is not registered
+func (r *Registry) MustGet(name string) TransactionManager {
+	tm, err := r.Get(name)
+	if err != nil {
+		panic(err)
+	}
+	return tm
+}
+
+// DataSources return the names of registered datasources in order
+func (r *Registry) DataSources() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.managers))
+	for name := range r.managers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,96 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	orders := registry.Register("orders", db)
+	stock := registry.Register("stock", db)
+
+	if _, err := registry.Get("payments"); !errors.Is(err, ErrDataSourceNotRegistered) {
+		t.Errorf("error should be %v, but %v", ErrDataSourceNotRegistered, err)
+	}
+	if tm := registry.MustGet("orders"); tm != orders {
+		t.Errorf("registered TransactionManager should be returned")
+	}
+	if names := registry.DataSources(); len(names) != 2 || names[0] != "orders" || names[1] != "stock" {
+		t.Errorf("datasources should be [orders stock], but %v", names)
+	}
+
+	DefaultTransactionTest("test-independent-datasources",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = orders.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = stock.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					if orders.GetDB(ctx).Statement.ConnPool == stock.GetDB(ctx).Statement.ConnPool {
+						t.Errorf("each datasource should resolve its own transaction")
+					}
+					if txCtx, ok := FromDataSourceContext(ctx, "orders"); !ok || txCtx.DataSource() != "orders" {
+						t.Errorf("transaction of orders should be bound to ctx")
+					}
+					if txCtx, ok := FromContext(ctx); !ok || txCtx.DataSource() != "stock" || !txCtx.IsRoot() {
+						t.Errorf("innermost transaction should be the new one of stock")
+					}
+					return stock.GetDB(ctx).Create(user1).Error
+				})
+				orders.GetDB(ctx).Create(user2)
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertNotExist(user2, t)
+		},
+	)
+
+	DefaultTransactionTest("test-not-supported-datasource",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = orders.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = stock.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					if _, ok := FromContext(ctx); !ok {
+						t.Errorf("transaction of orders should stay bound to ctx")
+					}
+					if _, ok := orders.GetDB(ctx).Statement.ConnPool.(gorm.TxCommitter); !ok {
+						t.Errorf("transaction of orders should not be suspended")
+					}
+					return nil
+				}, PropagationNotSupported)
+				orders.GetDB(ctx).Create(user1)
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+		},
+	)
+
+	DefaultTransactionTest("test-not-supported-current-datasource",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = orders.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				return stock.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					// the transaction of stock is the innermost one, suspending it exposes the one of orders
+					return stock.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+						if txCtx, ok := FromContext(ctx); !ok || txCtx.DataSource() != "orders" {
+							t.Errorf("transaction of orders should be the current one")
+						}
+						if err := RegisterSynchronization(ctx, Synchronization{}); err != nil {
+							t.Errorf("error should be nil, but %v", err)
+						}
+						return nil
+					}, PropagationNotSupported)
+				})
+			})
+		},
+		func(t *testing.T) {},
+	)
+}
@@ -0,0 +1,69 @@
+package transaction
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaPicker choose the replica serving a read, replicas is never empty
+type ReplicaPicker func(ctx context.Context, replicas []*gorm.DB) *gorm.DB
+
+// RoundRobin return a ReplicaPicker cycling through the replicas
+func RoundRobin() ReplicaPicker {
+	var next atomic.Uint64
+	return func(ctx context.Context, replicas []*gorm.DB) *gorm.DB {
+		return replicas[(next.Add(1)-1)%uint64(len(replicas))]
+	}
+}
+
+type stickySessionKey struct{}
+
+type stickySession struct {
+	lastCommit atomic.Int64
+}
+
+// StickySession scope the sticky primary window of WithStickyPrimary to the returned context, e.g. a request or a
+// user session, reads of other contexts are not affected by its commits. Reads are only sticky in a sticky session.
+func StickySession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stickySessionKey{}, &stickySession{})
+}
+
+// getReadDB return the db serving reads out of transaction, a replica unless ctx is in the sticky primary window
+func (m *transactionManager) getReadDB(ctx context.Context) *gorm.DB {
+	if len(m.replicas) == 0 || m.isSticky(ctx) {
+		return m.getPureDB(ctx)
+	}
+	return m.picker(ctx, m.replicas).WithContext(ctx)
+}
+
+// getBeginDB return the db a new transaction with options is begun on, read only transactions are begun on a replica
+func (m *transactionManager) getBeginDB(ctx context.Context, options *transactionOptions) *gorm.DB {
+	if options.readOnly {
+		return m.getReadDB(ctx)
+	}
+	return m.getPureDB(ctx)
+}
+
+// stick start the sticky primary window of ctx after a commit
+func (m *transactionManager) stick(ctx context.Context) {
+	if m.stickyWindow <= 0 {
+		return
+	}
+	if session, ok := ctx.Value(stickySessionKey{}).(*stickySession); ok {
+		session.lastCommit.Store(time.Now().UnixNano())
+	}
+}
+
+func (m *transactionManager) isSticky(ctx context.Context) bool {
+	if m.stickyWindow <= 0 {
+		return false
+	}
+	session, ok := ctx.Value(stickySessionKey{}).(*stickySession)
+	if !ok {
+		return false
+	}
+	last := session.lastCommit.Load()
+	return last != 0 && time.Since(time.Unix(0, last)) < m.stickyWindow
+}
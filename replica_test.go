@@ -0,0 +1,100 @@
+package transaction
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestTransactionManager_GetDB_Replicas(t *testing.T) {
+	replica1 := db.Session(&gorm.Session{NewDB: true})
+	replica2 := db.Session(&gorm.Session{NewDB: true})
+	var picked []*gorm.DB
+	rtm := NewTransactionManager(db,
+		WithReplicas(replica1, replica2),
+		WithReplicaPicker(func(ctx context.Context, replicas []*gorm.DB) *gorm.DB {
+			replica := replicas[len(picked)%len(replicas)]
+			picked = append(picked, replica)
+			return replica
+		}),
+	)
+	ctx := context.Background()
+
+	rtm.GetDB(ctx)
+	rtm.GetDB(ctx)
+	if len(picked) != 2 || picked[0] != replica1 || picked[1] != replica2 {
+		t.Errorf("reads out of transaction should be served by replicas")
+	}
+
+	picked = nil
+	_ = rtm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		if _, ok := rtm.GetDB(ctx).Statement.ConnPool.(gorm.TxCommitter); !ok {
+			t.Errorf("reads in read-write transaction should be served by the transaction")
+		}
+		return nil
+	})
+	if len(picked) != 0 {
+		t.Errorf("read-write transaction should be begun on the primary")
+	}
+
+	_ = rtm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		return nil
+	}, WithReadOnly())
+	if len(picked) != 1 {
+		t.Errorf("read only transaction should be begun on a replica")
+	}
+
+	// writes out of transaction, e.g. the ones surviving an outer rollback, go to the primary
+	for _, p := range []Propagation{PropagationSupports, PropagationNotSupported, PropagationNever} {
+		picked = nil
+		_ = rtm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			return nil
+		}, p)
+		if len(picked) != 0 {
+			t.Errorf("%v call out of transaction should be served by the primary", p)
+		}
+	}
+}
+
+func TestTransactionManager_GetDB_StickyPrimary(t *testing.T) {
+	var picked int
+	rtm := NewTransactionManager(db,
+		WithReplicas(db.Session(&gorm.Session{NewDB: true})),
+		WithReplicaPicker(func(ctx context.Context, replicas []*gorm.DB) *gorm.DB {
+			picked++
+			return replicas[0]
+		}),
+		WithStickyPrimary(100*time.Millisecond),
+	)
+	session1 := StickySession(context.Background())
+	session2 := StickySession(context.Background())
+
+	_ = rtm.Transaction(session1, func(ctx context.Context, tx *gorm.DB) error {
+		return nil
+	})
+	rtm.GetDB(session1)
+	if picked != 0 {
+		t.Errorf("reads after commit should be served by the primary")
+	}
+	rtm.GetDB(session2)
+	if picked != 1 {
+		t.Errorf("reads of another sticky session should be served by replicas")
+	}
+
+	// commits out of sticky sessions don't make any read sticky
+	_ = rtm.Transaction(context.Background(), func(ctx context.Context, tx *gorm.DB) error {
+		return nil
+	})
+	rtm.GetDB(context.Background())
+	rtm.GetDB(session2)
+	if picked != 3 {
+		t.Errorf("reads after a commit out of sticky session should be served by replicas")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	rtm.GetDB(session1)
+	if picked != 4 {
+		t.Errorf("reads after the sticky window should be served by replicas")
+	}
+}
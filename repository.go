@@ -6,6 +6,6 @@ type Repository interface {
 	TransactionManager
 }
 
-func NewRepository(db *gorm.DB) Repository {
-	return NewTransactionManager(db)
+func NewRepository(db *gorm.DB, opts ...ManagerOption) Repository {
+	return NewTransactionManager(db, opts...)
 }
@@ -0,0 +1,152 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// RetryPolicy describe how a failed transaction is retried, see WithRetry
+type RetryPolicy struct {
+	// MaxAttempts is the total number of executions including the first one, values below 1 mean 1
+	MaxAttempts int
+	// InitialBackoff is the wait time before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait time between two attempts, 0 means no cap
+	MaxBackoff time.Duration
+	// Multiplier grows the wait time after each retry, values below 1 mean a constant backoff
+	Multiplier float64
+	// Jitter randomize the wait time by ±Jitter*backoff, it should be within [0, 1]
+	Jitter float64
+	// IsRetryable classify the error returned by the transaction, nil means IsRetryableError
+	IsRetryable func(err error) bool
+	// OnRetry is invoked before every retry with the failed attempt number (starting from 1) and its error
+	OnRetry func(attempt int, err error)
+}
+
+// DefaultRetryPolicy retry deadlocks and serialization failures of PostgreSQL and SQLite up to 3 attempts, see
+// mysqltransaction.DefaultRetryPolicy for MySQL
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		IsRetryable:    IsRetryableError,
+	}
+}
+
+// RetryError is returned when a retryable error still occurs after RetryPolicy.MaxAttempts attempts
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("transaction failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+func (p *RetryPolicy) execute(ctx context.Context, fn func() error, onRetry func(attempt int, err error)) error {
+	isRetryable := p.IsRetryable
+	if isRetryable == nil {
+		isRetryable = IsRetryableError
+	}
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err)
+		}
+		onRetry(attempt, err)
+		if err := sleep(ctx, p.jitter(backoff)); err != nil {
+			return err
+		}
+		backoff = p.next(backoff)
+	}
+}
+
+func (p *RetryPolicy) jitter(backoff time.Duration) time.Duration {
+	if p.Jitter <= 0 || backoff <= 0 {
+		return backoff
+	}
+	delta := p.Jitter * float64(backoff)
+	return backoff + time.Duration(delta*(2*rand.Float64()-1))
+}
+
+func (p *RetryPolicy) next(backoff time.Duration) time.Duration {
+	if p.Multiplier > 1 {
+		backoff = time.Duration(float64(backoff) * p.Multiplier)
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// AnyRetryable combine classifiers, the error is retryable if any of them accepts it
+func AnyRetryable(classifiers ...func(err error) bool) func(err error) bool {
+	return func(err error) bool {
+		for _, classifier := range classifiers {
+			if classifier(err) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// IsRetryableError accept the retryable errors of PostgreSQL and SQLite. The errors of MySQL are classified by
+// mysqltransaction.IsRetryableError, so that the package doesn't link the MySQL driver.
+func IsRetryableError(err error) bool {
+	return IsRetryablePostgresError(err) || IsRetryableSQLiteError(err)
+}
+
+// IsRetryablePostgresError accept serialization failure (40001) and deadlock (40P01), the error must expose
+// its SQLSTATE like pgconn.PgError does
+func IsRetryablePostgresError(err error) bool {
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) {
+		state := pgErr.SQLState()
+		return state == "40001" || state == "40P01"
+	}
+	return false
+}
+
+// IsRetryableSQLiteError accept SQLITE_BUSY (5) and SQLITE_LOCKED (6)
+func IsRetryableSQLiteError(err error) bool {
+	var codeErr interface{ Code() int }
+	if errors.As(err, &codeErr) {
+		code := codeErr.Code() & 0xff // extended result codes keep the primary code in the low byte
+		return code == 5 || code == 6
+	}
+	return err != nil && (strings.Contains(err.Error(), "database is locked") ||
+		strings.Contains(err.Error(), "database table is locked"))
+}
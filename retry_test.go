@@ -0,0 +1,151 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"testing"
+)
+
+type mockPgError struct {
+	state string
+}
+
+var mockDeadlock = &mockPgError{state: "40P01"}
+
+func (e *mockPgError) Error() string {
+	return "pg error " + e.state
+}
+
+func (e *mockPgError) SQLState() string {
+	return e.state
+}
+
+func testRetryPolicy(attempts int, retries *[]int) RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = attempts
+	policy.InitialBackoff = 0
+	policy.OnRetry = func(attempt int, err error) {
+		*retries = append(*retries, attempt)
+	}
+	return policy
+}
+
+func TestTransactionManager_Transaction_Retry(t *testing.T) {
+
+	var err error
+	var retries []int
+	DefaultTransactionTest("test-retry-until-commit",
+		t,
+		func() {
+			retries = nil
+			ctx := context.Background()
+			attempt := 0
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				attempt++
+				tx.Create(&User{Username: fmt.Sprintf("test_user_attempt_%d", attempt)})
+				if attempt < 3 {
+					return mockDeadlock
+				}
+				tx.Create(user1)
+				return nil
+			}, WithRetry(testRetryPolicy(3, &retries)))
+		},
+		func(t *testing.T) {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			AssertExist(user1, t)
+			AssertNotExist(&User{Username: "test_user_attempt_1"}, t)
+			AssertNotExist(&User{Username: "test_user_attempt_2"}, t)
+			AssertExist(&User{Username: "test_user_attempt_3"}, t)
+			if len(retries) != 2 {
+				t.Errorf("retries: %v, expected 2 retries", retries)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-retry-exhausted",
+		t,
+		func() {
+			retries = nil
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return mockDeadlock
+			}, WithRetry(testRetryPolicy(2, &retries)))
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			var retryErr *RetryError
+			if !errors.As(err, &retryErr) || retryErr.Attempts != 2 {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !errors.Is(err, mockDeadlock) {
+				t.Errorf("error %v should wrap %v", err, mockDeadlock)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-not-retryable",
+		t,
+		func() {
+			retries = nil
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return mockErr
+			}, WithRetry(testRetryPolicy(3, &retries)))
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertErrorsIsEqual(err, mockErr, t)
+			if len(retries) != 0 {
+				t.Errorf("retries: %v, expected no retry", retries)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-no-retry-in-transaction",
+		t,
+		func() {
+			retries = nil
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return mockDeadlock
+				}, WithRetry(testRetryPolicy(3, &retries)))
+			})
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertNotExist(user2, t)
+			AssertErrorsIsEqual(err, mockDeadlock, t)
+			if len(retries) != 0 {
+				t.Errorf("retries: %v, expected no retry", retries)
+			}
+		},
+	)
+}
+
+func TestIsRetryableError(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{mockDeadlock, true},
+		{fmt.Errorf("wrapped: %w", &mockPgError{state: "40001"}), true},
+		{&mockPgError{state: "23505"}, false},
+		{errors.New("database is locked"), true},
+		{mockErr, false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if IsRetryableError(c.err) != c.retryable {
+			t.Errorf("IsRetryableError(%v) should be %v", c.err, c.retryable)
+		}
+	}
+}
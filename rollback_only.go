@@ -0,0 +1,66 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnexpectedRollback                = errors.New("transaction rolled back because it has been marked as rollback only")
+	ErrSetRollbackOnlyWithoutTransaction = errors.New("not in transaction, can't set rollback only")
+
+	errParticipationPanicked = errors.New("participating transaction panicked")
+)
+
+// SetRollbackOnly mark the transaction of ctx rollback only, the root transaction will roll back instead of
+// committing and return ErrUnexpectedRollback
+func SetRollbackOnly(ctx context.Context) error {
+	txCtx, ok := fromContext(ctx)
+	if !ok || !txCtx.InTransaction() {
+		return ErrSetRollbackOnlyWithoutTransaction
+	}
+	txCtx.Root().markRollbackOnly(nil)
+	return nil
+}
+
+// IsRollbackOnly report whether the transaction of ctx has been marked rollback only
+func IsRollbackOnly(ctx context.Context) bool {
+	txCtx, ok := fromContext(ctx)
+	if !ok || !txCtx.InTransaction() {
+		return false
+	}
+	rollbackOnly, _ := txCtx.Root().rollbackState()
+	return rollbackOnly
+}
+
+func (c *transactionContext) markRollbackOnly(cause error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.rollbackOnly {
+		c.rollbackOnly = true
+		c.rollbackCause = cause
+	}
+}
+
+// rollbackState return whether the root transaction c is marked rollback only and the cause of the mark
+func (c *transactionContext) rollbackState() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rollbackOnly, c.rollbackCause
+}
+
+// restoreRollbackState reset the rollback only mark of the root transaction c, e.g. when a savepoint rolls back
+func (c *transactionContext) restoreRollbackState(rollbackOnly bool, cause error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rollbackOnly, c.rollbackCause = rollbackOnly, cause
+}
+
+func (c *transactionContext) unexpectedRollbackError() error {
+	_, cause := c.rollbackState()
+	if cause == nil {
+		return ErrUnexpectedRollback
+	}
+	return fmt.Errorf("%w: %w", ErrUnexpectedRollback, cause)
+}
@@ -0,0 +1,162 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"sync"
+	"testing"
+)
+
+func TestTransactionManager_Transaction_RollbackOnly(t *testing.T) {
+
+	var err error
+	DefaultTransactionTest("test-swallowed-error-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				// err are ignored, but the transaction is rollback only
+				_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return mockErr
+				}, PropagationRequired)
+
+				if !IsRollbackOnly(ctx) {
+					t.Errorf("transaction should be rollback only")
+				}
+				return nil
+			}, PropagationRequired)
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertNotExist(user2, t)
+			if !errors.Is(err, ErrUnexpectedRollback) || !errors.Is(err, mockErr) {
+				t.Errorf("error %v should be %v caused by %v", err, ErrUnexpectedRollback, mockErr)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-swallowed-panic-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				func() {
+					defer _recover()
+					_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+						tx.Create(user2)
+						mockPanic()
+						return nil
+					}, PropagationMandatory)
+				}()
+
+				return nil
+			}, PropagationRequired)
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertNotExist(user2, t)
+			if !errors.Is(err, ErrUnexpectedRollback) {
+				t.Errorf("error %v should be %v", err, ErrUnexpectedRollback)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-set-rollback-only",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return SetRollbackOnly(ctx)
+			})
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertErrorsIsEqual(err, ErrUnexpectedRollback, t)
+		},
+	)
+
+	DefaultTransactionTest("test-nested-savepoint-is-rollback-only-boundary",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+
+					// err are ignored, but the savepoint is rollback only
+					_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+						tx.Create(user3)
+						return mockErr
+					})
+					return nil
+				}, PropagationNested)
+
+				if IsRollbackOnly(ctx) {
+					t.Errorf("transaction should not be rollback only")
+				}
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertNotExist(user2, t)
+			AssertNotExist(user3, t)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-concurrent-participants-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				// the participants mark the shared root transaction concurrently
+				var wg sync.WaitGroup
+				for i := 0; i < 4; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+							return mockErr
+						}, PropagationRequired)
+					}()
+				}
+				wg.Wait()
+
+				if !IsRollbackOnly(ctx) {
+					t.Errorf("transaction should be rollback only")
+				}
+				return nil
+			}, PropagationRequired)
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			if !errors.Is(err, ErrUnexpectedRollback) || !errors.Is(err, mockErr) {
+				t.Errorf("error %v should be %v caused by %v", err, ErrUnexpectedRollback, mockErr)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-set-rollback-only-without-transaction",
+		t,
+		func() {
+			err = SetRollbackOnly(context.Background())
+		},
+		func(t *testing.T) {
+			AssertErrorsIsEqual(err, ErrSetRollbackOnlyWithoutTransaction, t)
+		},
+	)
+}
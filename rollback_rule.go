@@ -0,0 +1,64 @@
+package transaction
+
+import (
+	"errors"
+)
+
+// ErrorMatcher decide whether an error returned by bizFn matches a rollback rule, see RollbackFor and NoRollbackFor
+type ErrorMatcher func(err error) bool
+
+// ErrorIs match the errors for which errors.Is(err, target) is true
+func ErrorIs(target error) ErrorMatcher {
+	return func(err error) bool {
+		return errors.Is(err, target)
+	}
+}
+
+// ErrorAs match the errors having an error of type T in their chain, like errors.As
+func ErrorAs[T error]() ErrorMatcher {
+	return func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	}
+}
+
+// RollbackFor make the errors matching one of matchers roll back even if they match NoRollbackFor, e.g. to narrow a
+// NoRollbackFor rule. Every error rolls back by default, so RollbackFor alone changes nothing.
+func RollbackFor(matchers ...ErrorMatcher) TransactionOption {
+	return transactionOptionFunc(func(options *transactionOptions) {
+		options.rollbackFor = append(options.rollbackFor, matchers...)
+	})
+}
+
+// NoRollbackFor make the errors matching one of matchers commit the work done by bizFn, they are still returned by
+// Transaction. A participating call failing with such an error doesn't mark the transaction rollback only, and a
+// PropagationNested call releases its savepoint.
+func NoRollbackFor(matchers ...ErrorMatcher) TransactionOption {
+	return transactionOptionFunc(func(options *transactionOptions) {
+		options.noRollbackFor = append(options.noRollbackFor, matchers...)
+	})
+}
+
+// rollbackOn report whether err returned by bizFn rolls back, every error does unless it matches NoRollbackFor
+// without matching RollbackFor
+func (o *transactionOptions) rollbackOn(err error) bool {
+	if err == nil {
+		return false
+	}
+	return !matchAny(o.noRollbackFor, err) || matchAny(o.rollbackFor, err)
+}
+
+func matchAny(matchers []ErrorMatcher, err error) bool {
+	for _, matcher := range matchers {
+		if matcher != nil && matcher(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// RollbackOn report whether err returned by bizFn rolls back a call made with opts, it lets decorators and other
+// implementations of TransactionManager follow RollbackFor and NoRollbackFor
+func RollbackOn(err error, opts ...TransactionOption) bool {
+	return newTransactionOptions(opts).rollbackOn(err)
+}
@@ -0,0 +1,182 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"testing"
+)
+
+type mockWarning struct{}
+
+func (mockWarning) Error() string {
+	return "mock warning"
+}
+
+func TestTransactionManager_Transaction_RollbackRules(t *testing.T) {
+
+	var err error
+	DefaultTransactionTest("test-no-rollback-for-is",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return mockErr
+			}, NoRollbackFor(ErrorIs(mockErr)))
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertErrorsIsEqual(err, mockErr, t)
+		},
+	)
+
+	DefaultTransactionTest("test-no-rollback-for-as",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return mockWarning{}
+			}, NoRollbackFor(ErrorAs[mockWarning]()))
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertErrorsIsEqual(err, mockWarning{}, t)
+		},
+	)
+
+	DefaultTransactionTest("test-no-rollback-for-not-matched",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return mockErr
+			}, NoRollbackFor(ErrorAs[mockWarning]()))
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertErrorsIsEqual(err, mockErr, t)
+		},
+	)
+
+	DefaultTransactionTest("test-rollback-for-alone",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return context.Canceled
+			}, RollbackFor(ErrorIs(mockErr)))
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertErrorsIsEqual(err, context.Canceled, t)
+		},
+	)
+
+	DefaultTransactionTest("test-rollback-for-narrow",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return fmt.Errorf("%w: %w", mockWarning{}, mockErr)
+			}, NoRollbackFor(ErrorAs[mockWarning]()), RollbackFor(func(err error) bool {
+				return errors.Is(err, mockErr)
+			}))
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+		},
+	)
+
+	DefaultTransactionTest("test-rollback-for-precedence",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return mockErr
+			}, RollbackFor(ErrorIs(mockErr)), NoRollbackFor(ErrorIs(mockErr)))
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertErrorsIsEqual(err, mockErr, t)
+		},
+	)
+
+	DefaultTransactionTest("test-no-rollback-for-participation",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				// the error doesn't mark the transaction rollback only
+				_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return mockErr
+				}, PropagationRequired, NoRollbackFor(ErrorIs(mockErr)))
+
+				if IsRollbackOnly(ctx) {
+					t.Errorf("transaction should not be rollback only")
+				}
+				return nil
+			}, PropagationRequired)
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertExist(user2, t)
+			if err != nil {
+				t.Errorf("error should be nil, but %v", err)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-no-rollback-for-nested",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				// the savepoint is released rather than rolled back
+				nestedErr := tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return mockErr
+				}, PropagationNested, NoRollbackFor(ErrorIs(mockErr)))
+
+				AssertErrorsIsEqual(nestedErr, mockErr, t)
+				return nil
+			}, PropagationRequired)
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertExist(user2, t)
+			if err != nil {
+				t.Errorf("error should be nil, but %v", err)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-no-rollback-for-rollback-only",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				_ = SetRollbackOnly(ctx)
+				return mockErr
+			}, NoRollbackFor(ErrorIs(mockErr)))
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			if !errors.Is(err, ErrUnexpectedRollback) {
+				t.Errorf("error %v should be %v", err, ErrUnexpectedRollback)
+			}
+		},
+	)
+}
@@ -0,0 +1,50 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+type savePointScopeKey struct{}
+
+// WithSavePointScope make the Transaction calls using ctx with PropagationRequired, PropagationRequiresNew or
+// PropagationNested participate in the existing transaction of their datasource with a savepoint, instead of
+// beginning and committing a transaction of their own, see transactiontest.WithRollback
+func WithSavePointScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, savePointScopeKey{}, true)
+}
+
+// inSavePointScope report whether a call with propagation runs in a savepoint because of WithSavePointScope
+func (m *transactionManager) inSavePointScope(ctx context.Context, propagation Propagation) bool {
+	switch propagation {
+	case PropagationRequired, PropagationRequiresNew, PropagationNested:
+	default:
+		return false
+	}
+	if scoped, _ := ctx.Value(savePointScopeKey{}).(bool); !scoped {
+		return false
+	}
+	txCtx, ok := m.fromContext(ctx)
+	return ok && txCtx.InTransaction()
+}
+
+// nextSavePoint return a savepoint name unique in the root transaction, e.g. sp_2_5 is the 5th savepoint of the
+// root transaction and it's nested in another savepoint
+func (c *transactionContext) nextSavePoint() string {
+	root := c.Root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	root.savepointSeq++
+	return fmt.Sprintf("sp_%d_%d", c.savepointDepth+1, root.savepointSeq)
+}
+
+// releaseSavePoint release the savepoint on dialects supporting RELEASE SAVEPOINT, gorm doesn't provide it
+func releaseSavePoint(db *gorm.DB, name string) error {
+	switch db.Dialector.Name() {
+	case "mysql", "postgres", "sqlite":
+		return db.Exec("RELEASE SAVEPOINT " + name).Error
+	default:
+		return nil
+	}
+}
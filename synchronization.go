@@ -0,0 +1,130 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrSynchronizationWithoutTransaction = errors.New("not in transaction, can't register synchronization")
+
+type CompletionStatus int8
+
+const (
+	StatusCommitted  CompletionStatus = iota // 事务已提交
+	StatusRolledBack                         // 事务已回滚
+)
+
+// Synchronization is a set of callbacks bound to the root transaction, all of them are optional
+type Synchronization struct {
+	// BeforeCommit is invoked before the root transaction commits, a non-nil error rolls back the transaction
+	BeforeCommit func(ctx context.Context) error
+	// AfterCommit is invoked after the root transaction committed
+	AfterCommit func(ctx context.Context)
+	// AfterRollback is invoked after the root transaction, or the savepoint it's registered in, rolled back
+	AfterRollback func(ctx context.Context)
+	// AfterCompletion is invoked after AfterCommit or AfterRollback with the final outcome. A panic of AfterCommit,
+	// AfterRollback or AfterCompletion doesn't stop the other callbacks nor change the outcome, it's raised again
+	// once all of them have run
+	AfterCompletion func(ctx context.Context, status CompletionStatus)
+}
+
+// RegisterSynchronization attach sync to the root transaction of ctx. Synchronizations registered inside a
+// PropagationNested savepoint are completed as rolled back when the savepoint rolls back, synchronizations
+// registered inside a PropagationRequiresNew transaction are completed with that inner transaction.
+func RegisterSynchronization(ctx context.Context, sync Synchronization) error {
+	txCtx, ok := fromContext(ctx)
+	if !ok || !txCtx.InTransaction() {
+		return ErrSynchronizationWithoutTransaction
+	}
+	root := txCtx.Root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	root.synchronizations = append(root.synchronizations, sync)
+	return nil
+}
+
+// synchronizationMark return the number of synchronizations registered to the root transaction c, see
+// rollbackSynchronizationsTo
+func (c *transactionContext) synchronizationMark() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.synchronizations)
+}
+
+// beforeCommitAt return the BeforeCommit callback of the i-th synchronization, false if there are only i of them
+func (c *transactionContext) beforeCommitAt(i int) (func(ctx context.Context) error, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i >= len(c.synchronizations) {
+		return nil, false
+	}
+	return c.synchronizations[i].BeforeCommit, true
+}
+
+func (c *transactionContext) triggerBeforeCommit() error {
+	// synchronizations may register new synchronizations, so the callbacks run without holding the lock
+	for i := 0; ; i++ {
+		fn, ok := c.beforeCommitAt(i)
+		if !ok {
+			return nil
+		}
+		if fn != nil {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *transactionContext) triggerAfterCompletion(status CompletionStatus) {
+	c.mu.Lock()
+	syncs := c.synchronizations
+	c.synchronizations = nil
+	c.mu.Unlock()
+	completeSynchronizations(c.ctx, syncs, status)
+}
+
+// rollbackSynchronizationsTo complete synchronizations registered after mark as rolled back
+func (c *transactionContext) rollbackSynchronizationsTo(mark int) {
+	c.mu.Lock()
+	if mark >= len(c.synchronizations) {
+		c.mu.Unlock()
+		return
+	}
+	syncs := c.synchronizations[mark:]
+	c.synchronizations = c.synchronizations[:mark:mark]
+	c.mu.Unlock()
+	completeSynchronizations(c.ctx, syncs, StatusRolledBack)
+}
+
+// completeSynchronizations run the callbacks of syncs for status, a panicking callback doesn't stop the others and
+// its panic is raised again once all of them have run
+func completeSynchronizations(ctx context.Context, syncs []Synchronization, status CompletionStatus) {
+	var recovered interface{}
+	run := func(fn func()) {
+		defer func() {
+			if r := recover(); r != nil && recovered == nil {
+				recovered = r
+			}
+		}()
+		fn()
+	}
+	for _, sync := range syncs {
+		sync := sync
+		if status == StatusCommitted && sync.AfterCommit != nil {
+			run(func() { sync.AfterCommit(ctx) })
+		}
+		if status == StatusRolledBack && sync.AfterRollback != nil {
+			run(func() { sync.AfterRollback(ctx) })
+		}
+	}
+	for _, sync := range syncs {
+		sync := sync
+		if sync.AfterCompletion != nil {
+			run(func() { sync.AfterCompletion(ctx, status) })
+		}
+	}
+	if recovered != nil {
+		panic(recovered)
+	}
+}
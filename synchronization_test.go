@@ -0,0 +1,271 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func recordSynchronization(events *[]string, name string) Synchronization {
+	return Synchronization{
+		BeforeCommit: func(ctx context.Context) error {
+			*events = append(*events, name+":before-commit")
+			return nil
+		},
+		AfterCommit: func(ctx context.Context) {
+			*events = append(*events, name+":after-commit")
+		},
+		AfterRollback: func(ctx context.Context) {
+			*events = append(*events, name+":after-rollback")
+		},
+		AfterCompletion: func(ctx context.Context, status CompletionStatus) {
+			if status == StatusCommitted {
+				*events = append(*events, name+":completed")
+			} else {
+				*events = append(*events, name+":rolled-back")
+			}
+		},
+	}
+}
+
+func AssertEvents(events []string, expected []string, t *testing.T) {
+	if len(events) != len(expected) {
+		t.Errorf("events: %v, expected: %v", events, expected)
+		return
+	}
+	for i := range events {
+		if events[i] != expected[i] {
+			t.Errorf("events: %v, expected: %v", events, expected)
+			return
+		}
+	}
+}
+
+func TestRegisterSynchronization(t *testing.T) {
+
+	var events []string
+	DefaultTransactionTest("test-after-commit-only-on-root",
+		t,
+		func() {
+			events = nil
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				_ = RegisterSynchronization(ctx, recordSynchronization(&events, "outer"))
+
+				return tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					_ = RegisterSynchronization(ctx, recordSynchronization(&events, "inner"))
+					events = append(events, "inner-done")
+					return nil
+				})
+			})
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertExist(user2, t)
+			AssertEvents(events, []string{
+				"inner-done",
+				"outer:before-commit", "inner:before-commit",
+				"outer:after-commit", "inner:after-commit",
+				"outer:completed", "inner:completed",
+			}, t)
+		},
+	)
+
+	var committed atomic.Int32
+	DefaultTransactionTest("test-concurrent-registration",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				// the participants register to the shared root transaction concurrently
+				var wg sync.WaitGroup
+				for i := 0; i < 4; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+							return RegisterSynchronization(ctx, Synchronization{
+								AfterCommit: func(ctx context.Context) {
+									committed.Add(1)
+								},
+							})
+						}, PropagationRequired)
+					}()
+				}
+				wg.Wait()
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			if n := committed.Load(); n != 4 {
+				t.Errorf("%v synchronizations should be committed, but %v", 4, n)
+			}
+		},
+	)
+
+	var err error
+	DefaultTransactionTest("test-before-commit-veto",
+		t,
+		func() {
+			events = nil
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				sync := recordSynchronization(&events, "veto")
+				sync.BeforeCommit = func(ctx context.Context) error {
+					return mockErr
+				}
+				return RegisterSynchronization(ctx, sync)
+			})
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertErrorsIsEqual(err, mockErr, t)
+			AssertEvents(events, []string{"veto:after-rollback", "veto:rolled-back"}, t)
+		},
+	)
+
+	DefaultTransactionTest("test-rollback-panic",
+		t,
+		func() {
+			events = nil
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				_ = RegisterSynchronization(ctx, recordSynchronization(&events, "outer"))
+				mockPanic()
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertEvents(events, []string{"outer:after-rollback", "outer:rolled-back"}, t)
+		},
+	)
+
+	DefaultTransactionTest("test-nested-savepoint-rollback",
+		t,
+		func() {
+			events = nil
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				_ = RegisterSynchronization(ctx, recordSynchronization(&events, "outer"))
+
+				_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					_ = RegisterSynchronization(ctx, recordSynchronization(&events, "nested"))
+					return mockErr
+				}, PropagationNested)
+
+				events = append(events, "outer-done")
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertNotExist(user2, t)
+			AssertEvents(events, []string{
+				"nested:after-rollback", "nested:rolled-back",
+				"outer-done",
+				"outer:before-commit", "outer:after-commit", "outer:completed",
+			}, t)
+		},
+	)
+
+	DefaultTransactionTest("test-requires-new-completes-separately",
+		t,
+		func() {
+			events = nil
+			ctx := context.Background()
+			_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = RegisterSynchronization(ctx, recordSynchronization(&events, "outer"))
+
+				_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user1)
+					return RegisterSynchronization(ctx, recordSynchronization(&events, "new"))
+				}, PropagationRequiresNew)
+
+				events = append(events, "outer-done")
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertEvents(events, []string{
+				"new:before-commit", "new:after-commit", "new:completed",
+				"outer-done",
+				"outer:after-rollback", "outer:rolled-back",
+			}, t)
+		},
+	)
+
+	DefaultTransactionTest("test-register-without-transaction",
+		t,
+		func() {
+			err = RegisterSynchronization(context.Background(), Synchronization{})
+		},
+		func(t *testing.T) {
+			AssertErrorsIsEqual(err, ErrSynchronizationWithoutTransaction, t)
+		},
+	)
+}
+
+type recordingObserver struct {
+	NoopMetricsObserver
+	events []string
+}
+
+func (o *recordingObserver) OnCommit(TransactionInfo, time.Duration) {
+	o.events = append(o.events, "commit")
+}
+
+func (o *recordingObserver) OnRollback(TransactionInfo, time.Duration) {
+	o.events = append(o.events, "rollback")
+}
+
+func (o *recordingObserver) OnPanic(TransactionInfo) {
+	o.events = append(o.events, "panic")
+}
+
+func TestRegisterSynchronization_PanicAfterCommit(t *testing.T) {
+	observer := &recordingObserver{}
+	recoveringTm := NewTransactionManager(db, WithPanicRecovery(true), WithMetricsObserver(observer))
+
+	var events []string
+	var err error
+	DefaultTransactionTest("test-after-commit-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			err = recoveringTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				_ = RegisterSynchronization(ctx, Synchronization{
+					AfterCommit: func(ctx context.Context) {
+						mockPanic()
+					},
+				})
+				_ = RegisterSynchronization(ctx, recordSynchronization(&events, "second"))
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			AssertEvents(events, []string{"second:before-commit", "second:after-commit", "second:completed"}, t)
+			AssertEvents(observer.events, []string{"commit"}, t)
+			var panicErr *PanicError
+			if !errors.As(err, &panicErr) || !panicErr.Committed {
+				t.Errorf("error %v should be a committed *PanicError", err)
+			}
+		},
+	)
+}
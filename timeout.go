@@ -0,0 +1,29 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrTransactionTimeout = errors.New("transaction timeout")
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// timeoutError wrap err with ErrTransactionTimeout when the deadline of timeoutCtx, rather than one of parent,
+// has been exceeded
+func timeoutError(timeoutCtx context.Context, parent context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrTransactionTimeout) {
+		return err
+	}
+	if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+		return fmt.Errorf("%w: %w", ErrTransactionTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestTransactionManager_Transaction_Timeout(t *testing.T) {
+
+	var err error
+	DefaultTransactionTest("test-timeout-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				<-ctx.Done()
+				return tx.Create(user2).Error
+			}, WithTimeout(50*time.Millisecond))
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			AssertNotExist(user2, t)
+			if !errors.Is(err, ErrTransactionTimeout) {
+				t.Errorf("error %v should be %v", err, ErrTransactionTimeout)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-timeout-not-exceeded",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				return tx.Create(user1).Error
+			}, WithTimeout(time.Minute))
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		},
+	)
+
+	var outerDeadline, innerDeadline time.Time
+	DefaultTransactionTest("test-inherit-tighter-deadline",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				outerDeadline, _ = ctx.Deadline()
+				return tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					innerDeadline, _ = ctx.Deadline()
+					return tx.Create(user1).Error
+				}, PropagationRequired, WithTimeout(time.Hour))
+			}, WithTimeout(time.Minute))
+		},
+		func(t *testing.T) {
+			AssertExist(user1, t)
+			if outerDeadline.IsZero() || !innerDeadline.Equal(outerDeadline) {
+				t.Errorf("inner deadline %v should equal outer deadline %v", innerDeadline, outerDeadline)
+			}
+		},
+	)
+
+	DefaultTransactionTest("test-inner-timeout-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			err = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					<-ctx.Done()
+					return ctx.Err()
+				}, PropagationRequired, WithTimeout(50*time.Millisecond))
+			}, WithTimeout(time.Minute))
+		},
+		func(t *testing.T) {
+			AssertNotExist(user1, t)
+			if !errors.Is(err, ErrTransactionTimeout) {
+				t.Errorf("error %v should be %v", err, ErrTransactionTimeout)
+			}
+		},
+	)
+}
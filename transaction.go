@@ -2,24 +2,46 @@ package transaction
 
 import (
 	"context"
+	"database/sql"
 	"errors"
-	"fmt"
 	"gorm.io/gorm"
+	"sync"
 	"time"
 )
 
 type Propagation int8
 
 const (
-	PropagationRequired     = iota // 如果存在一个事务，则支持当前事务，如果当前没有事务，就新建一个事务
-	PropagationSupports            // 如果存在一个事务，支持当前事务，如果当前没有事务，就以非事务方式执行
-	PropagationMandatory           // 如果存在一个事务，支持当前事务，如果当前没有事务，返回错误
-	PropagationRequiresNew         // 新建事务，如果当前存在事务，把当前事务挂起
-	PropagationNotSupported        // 以非事务方式执行操作，如果当前存在事务，就把当前事务挂起
-	PropagationNested              // 支持当前事务，新增Savepoint点，与当前事务同步提交或回滚
-	PropagationNever               // 以非事务方式执行，如果当前存在事务，直接返回错误
+	PropagationRequired     Propagation = iota // 如果存在一个事务，则支持当前事务，如果当前没有事务，就新建一个事务
+	PropagationSupports                        // 如果存在一个事务，支持当前事务，如果当前没有事务，就以非事务方式执行
+	PropagationMandatory                       // 如果存在一个事务，支持当前事务，如果当前没有事务，返回错误
+	PropagationRequiresNew                     // 新建事务，如果当前存在事务，把当前事务挂起
+	PropagationNotSupported                    // 以非事务方式执行操作，如果当前存在事务，就把当前事务挂起
+	PropagationNested                          // 支持当前事务，新增Savepoint点，与当前事务同步提交或回滚
+	PropagationNever                           // 以非事务方式执行，如果当前存在事务，直接返回错误
 )
 
+func (p Propagation) String() string {
+	switch p {
+	case PropagationRequired:
+		return "REQUIRED"
+	case PropagationSupports:
+		return "SUPPORTS"
+	case PropagationMandatory:
+		return "MANDATORY"
+	case PropagationRequiresNew:
+		return "REQUIRES_NEW"
+	case PropagationNotSupported:
+		return "NOT_SUPPORTED"
+	case PropagationNested:
+		return "NESTED"
+	case PropagationNever:
+		return "NEVER"
+	default:
+		return "UNKNOWN"
+	}
+}
+
 func defaultPropagation() Propagation {
 	return PropagationRequired
 }
@@ -28,12 +50,100 @@ var (
 	ErrCommitWithoutTransaction        = errors.New("not in transaction, can't commit")
 	ErrNeverPropInTransaction          = errors.New("never propagation must not in transaction")
 	ErrMandatoryPropWithoutTransaction = errors.New("mandatory propagation must in transaction")
+	ErrIsolationLevelNotSatisfied      = errors.New("isolation level is stricter than existing transaction")
+	ErrReadOnlyTransaction             = errors.New("existing transaction is read only, can't participate with write access")
 )
 
+// transactionContextKey bind the transaction of a datasource to a context, each datasource has its own key so that
+// transactions on different datasources don't collide
+type transactionContextKey struct {
+	datasource string
+}
+
+// currentTransactionKey bind the innermost transaction to a context, whatever its datasource is
+type currentTransactionKey struct{}
+
+// TransactionContext is the transaction bound to a context, see FromContext
+type TransactionContext interface {
+	context.Context
+	IsRoot() bool
+	// DataSource return the name of the datasource the transaction is begun on, see WithDataSource
+	DataSource() string
+	// Name return the composed name of the transaction, see WithName
+	Name() string
+	// Depth return the number of participating calls between the root transaction and this context
+	Depth() int
+	// SavePoint return the name of the innermost savepoint, empty if no PropagationNested savepoint is active
+	SavePoint() string
+	Ctx() context.Context
+	TxDB() *gorm.DB
+	TxError() error
+	InTransaction() bool
+}
+
+// FromContext return the innermost transaction bound to ctx, contexts derived from a transactional context by
+// context.WithValue, context.WithTimeout etc. keep participating in its transaction
+func FromContext(ctx context.Context) (TransactionContext, bool) {
+	if txCtx, ok := fromContext(ctx); ok && txCtx.InTransaction() {
+		return txCtx, true
+	}
+	return nil, false
+}
+
+// FromDataSourceContext return the transaction of datasource bound to ctx, see FromContext
+func FromDataSourceContext(ctx context.Context, datasource string) (TransactionContext, bool) {
+	if txCtx, ok := lookup(ctx, transactionContextKey{datasource: datasource}); ok && txCtx.InTransaction() {
+		return txCtx, true
+	}
+	return nil, false
+}
+
+func fromContext(ctx context.Context) (*transactionContext, bool) {
+	return lookup(ctx, currentTransactionKey{})
+}
+
+func lookup(ctx context.Context, key interface{}) (*transactionContext, bool) {
+	txCtx, ok := ctx.Value(key).(*transactionContext)
+	return txCtx, ok && txCtx != nil
+}
+
+// suspend hide the transaction bound to ctx under key from the calls using the returned context
+func suspend(ctx context.Context, key transactionContextKey) context.Context {
+	if _, ok := lookup(ctx, key); !ok {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, key, (*transactionContext)(nil))
+	if current, ok := fromContext(ctx); ok && current.key == key {
+		// the innermost transaction becomes the next outer one of another datasource, if any
+		outer := current
+		for outer != nil && outer.key == key {
+			outer, _ = lookup(outer.ctx, currentTransactionKey{})
+		}
+		ctx = context.WithValue(ctx, currentTransactionKey{}, outer)
+	}
+	return ctx
+}
+
 type transactionContext struct {
-	ctx    context.Context
-	tx     *gorm.DB
-	parent *transactionContext
+	key       transactionContextKey
+	ctx       context.Context
+	tx        *gorm.DB
+	parent    *transactionContext
+	txOptions sql.TxOptions
+
+	name string
+	// mu guard the state of the root transaction below, participants of the transaction may run concurrently
+	mu               sync.Mutex
+	synchronizations []Synchronization
+	rollbackOnly     bool
+	rollbackCause    error
+	depth            int
+	savepoint        string
+	savepointSeq     int
+	savepointDepth   int
+	// completed report whether the root transaction has physically committed or rolled back
+	completed bool
+	committed bool
 }
 
 func (c *transactionContext) Deadline() (deadline time.Time, ok bool) {
@@ -49,6 +159,9 @@ func (c *transactionContext) Err() error {
 }
 
 func (c *transactionContext) Value(key interface{}) interface{} {
+	if key == c.key || key == (currentTransactionKey{}) {
+		return c
+	}
 	return c.ctx.Value(key)
 }
 
@@ -56,6 +169,30 @@ func (c *transactionContext) IsRoot() bool {
 	return c.parent == nil
 }
 
+func (c *transactionContext) DataSource() string {
+	return c.key.datasource
+}
+
+func (c *transactionContext) Name() string {
+	return c.name
+}
+
+func (c *transactionContext) Depth() int {
+	return c.depth
+}
+
+func (c *transactionContext) SavePoint() string {
+	return c.savepoint
+}
+
+func (c *transactionContext) Root() *transactionContext {
+	root := c
+	for root.parent != nil {
+		root = root.parent
+	}
+	return root
+}
+
 func (c *transactionContext) Ctx() context.Context {
 	return c.ctx
 }
@@ -76,20 +213,46 @@ func (c *transactionContext) InTransaction() bool {
 		return false
 	}
 	committer, ok := c.tx.Statement.ConnPool.(gorm.TxCommitter)
-	return ok && committer != nil
+	// the contexts of a completed transaction, e.g. the one returned by Begin, are no longer in transaction
+	return ok && committer != nil && !c.Root().isCompleted()
+}
+
+func (c *transactionContext) isCompleted() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.completed
+}
+
+func (c *transactionContext) complete(committed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.completed, c.committed = true, committed
 }
 
 func (c *transactionContext) Session() *transactionContext {
-	return &transactionContext{
-		ctx:    c.ctx,
-		tx:     c.tx.WithContext(c.ctx),
-		parent: c,
+	return c.session(c.ctx)
+}
+
+func (c *transactionContext) session(ctx context.Context) *transactionContext {
+	session := &transactionContext{
+		key:            c.key,
+		ctx:            ctx,
+		parent:         c,
+		name:           c.name,
+		depth:          c.depth + 1,
+		savepoint:      c.savepoint,
+		savepointDepth: c.savepointDepth,
 	}
+	// statements of the session run with the session as context, so that it can be looked up from them
+	session.tx = c.tx.WithContext(session)
+	return session
 }
 
 func (c *transactionContext) Rollback() {
 	if c.InTransaction() && c.IsRoot() {
 		c.tx.Rollback()
+		c.complete(false)
+		c.triggerAfterCompletion(StatusRolledBack)
 	}
 }
 
@@ -98,7 +261,18 @@ func (c *transactionContext) Commit() error {
 		return ErrCommitWithoutTransaction
 	}
 	if c.IsRoot() {
-		return c.tx.Commit().Error
+		if rollbackOnly, _ := c.rollbackState(); rollbackOnly {
+			return c.unexpectedRollbackError()
+		}
+		if err := c.triggerBeforeCommit(); err != nil {
+			return err
+		}
+		if err := c.tx.Commit().Error; err != nil {
+			return err
+		}
+		// the outcome is settled before the synchronizations run, so that a panic of them can't undo it
+		c.complete(true)
+		c.triggerAfterCompletion(StatusCommitted)
 	}
 	return nil
 }
@@ -108,52 +282,91 @@ type TransactionManager interface {
 	GetDB(ctx context.Context) *gorm.DB
 	// GetOriginDB return original gorm.DB object
 	GetOriginDB() *gorm.DB
-	Transaction(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, propagations ...Propagation) error
+	// Transaction execute bizFn in transaction, opts can be Propagation or other TransactionOption
+	Transaction(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, opts ...TransactionOption) error
+	// Begin start a call like Transaction without callback, the call is completed by the returned TxHandle
+	Begin(ctx context.Context, opts ...TransactionOption) (context.Context, TxHandle, error)
 }
 
 type transactionManager struct {
-	db *gorm.DB
+	db               *gorm.DB
+	key              transactionContextKey
+	validateExisting bool
+	observer         MetricsObserver
+	replicas         []*gorm.DB
+	picker           ReplicaPicker
+	stickyWindow     time.Duration
+	watchdog         *Watchdog
+	recoverPanic     bool
+
+	globalRollbackOnParticipationFailure bool
 }
 
-func NewTransactionManager(db *gorm.DB) TransactionManager {
-	return &transactionManager{
-		db: db,
+func NewTransactionManager(db *gorm.DB, opts ...ManagerOption) TransactionManager {
+	m := &transactionManager{
+		db:                                   db,
+		observer:                             NoopMetricsObserver{},
+		picker:                               RoundRobin(),
+		globalRollbackOnParticipationFailure: true,
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+	return m
 }
 
 func (m *transactionManager) GetDB(ctx context.Context) *gorm.DB {
-	if txCtx, ok := ctx.(*transactionContext); ok && txCtx.tx != nil {
-		return txCtx.tx
+	if txCtx, ok := m.fromContext(ctx); ok && txCtx.tx != nil {
+		return txCtx.tx.WithContext(ctx)
 	}
-	return m.getPureDB(ctx)
+	return m.getReadDB(ctx)
 }
 
 func (m *transactionManager) GetOriginDB() *gorm.DB {
 	return m.db
 }
 
+// fromContext return the transaction of the datasource of m bound to ctx
+func (m *transactionManager) fromContext(ctx context.Context) (*transactionContext, bool) {
+	return lookup(ctx, m.key)
+}
+
 func (m *transactionManager) getPureDB(ctx context.Context) *gorm.DB {
 	return m.db.WithContext(ctx)
 }
 
-func (m *transactionManager) Transaction(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, propagations ...Propagation) error {
-	propagation := defaultPropagation()
-	if len(propagations) > 0 {
-		propagation = propagations[0]
+func (m *transactionManager) Transaction(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, opts ...TransactionOption) (err error) {
+	if m.recoverPanic {
+		defer recoverPanic(&err)
 	}
-	switch propagation {
+	options := newTransactionOptions(opts)
+	if m.inSavePointScope(ctx, options.propagation) {
+		options.propagation = PropagationNested
+	}
+	if options.retry != nil && m.beginsNewTransaction(ctx, options.propagation) {
+		return options.retry.execute(ctx, func() error {
+			return m.execute(ctx, bizFn, options)
+		}, func(attempt int, err error) {
+			m.observer.OnRetry(options.info(callName(ctx, options)), attempt, err)
+		})
+	}
+	return m.execute(ctx, bizFn, options)
+}
+
+func (m *transactionManager) execute(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, options *transactionOptions) error {
+	switch options.propagation {
 	case PropagationRequired:
-		return m.withRequiredPropagation(ctx, bizFn)
+		return m.withRequiredPropagation(ctx, bizFn, options)
 	case PropagationSupports:
-		return m.withSupportsPropagation(ctx, bizFn)
+		return m.withSupportsPropagation(ctx, bizFn, options)
 	case PropagationMandatory:
-		return m.withMandatoryPropagation(ctx, bizFn)
+		return m.withMandatoryPropagation(ctx, bizFn, options)
 	case PropagationRequiresNew:
-		return m.withRequiresNewPropagation(ctx, bizFn)
+		return m.withRequiresNewPropagation(ctx, bizFn, options)
 	case PropagationNotSupported:
 		return m.withNotSupportedPropagation(ctx, bizFn)
 	case PropagationNested:
-		return m.withNestedPropagation(ctx, bizFn)
+		return m.withNestedPropagation(ctx, bizFn, options)
 	case PropagationNever:
 		return m.withNeverPropagation(ctx, bizFn)
 	default:
@@ -161,8 +374,75 @@ func (m *transactionManager) Transaction(ctx context.Context, bizFn func(ctx con
 	}
 }
 
+// beginsNewTransaction report whether a call with propagation begins a new root transaction
+func (m *transactionManager) beginsNewTransaction(ctx context.Context, propagation Propagation) bool {
+	switch propagation {
+	case PropagationRequiresNew:
+		return true
+	case PropagationRequired, PropagationNested:
+		txCtx, ok := m.fromContext(ctx)
+		return !ok || !txCtx.InTransaction()
+	default:
+		return false
+	}
+}
+
+// checkExistingTransaction validate whether the existing transaction satisfies the isolation level
+// and access mode requested by a participating call, it's a no-op unless WithValidateExistingTransaction is set
+func (m *transactionManager) checkExistingTransaction(txCtx *transactionContext, options *transactionOptions) error {
+	if !m.validateExisting {
+		return nil
+	}
+	existing := txCtx.Root().txOptions
+	if options.isolation != sql.LevelDefault && options.isolation > existing.Isolation {
+		return ErrIsolationLevelNotSatisfied
+	}
+	if !options.readOnly && existing.ReadOnly {
+		return ErrReadOnlyTransaction
+	}
+	return nil
+}
+
+// participate execute bizFn in the existing transaction of txCtx, inside savepoint if it's not empty. The deadline
+// of a participating call is the tighter of its own timeout and the outer one. A failed participating call marks the
+// transaction rollback only unless WithGlobalRollbackOnParticipationFailure(false) is set.
+func (m *transactionManager) participate(ctx context.Context, txCtx *transactionContext, savepoint string, bizFn func(ctx context.Context, tx *gorm.DB) error, options *transactionOptions) error {
+	var err error
+	panicked := true
+	defer func() {
+		if m.globalRollbackOnParticipationFailure {
+			if panicked {
+				txCtx.Root().markRollbackOnly(errParticipationPanicked)
+			} else if options.rollbackOn(err) {
+				txCtx.Root().markRollbackOnly(err)
+			}
+		}
+	}()
+	session, cancel := m.newSession(ctx, txCtx, savepoint, options)
+	defer cancel()
+	err = timeoutError(session.ctx, ctx, bizFn(session, session.tx))
+	panicked = false
+	return err
+}
+
+// newSession create the session of a call participating in the existing transaction of txCtx, inside savepoint if
+// it's not empty, cancel must be called once the call completes
+func (m *transactionManager) newSession(ctx context.Context, txCtx *transactionContext, savepoint string, options *transactionOptions) (*transactionContext, context.CancelFunc) {
+	timeoutCtx, cancel := withTimeout(ctx, options.timeout)
+	session := txCtx.session(timeoutCtx)
+	if options.name != "" {
+		session.name = joinName(txCtx.name, options.name)
+		session.tx = withTransactionLogger(session.tx, session.name)
+	}
+	if savepoint != "" {
+		session.savepoint = savepoint
+		session.savepointDepth++
+	}
+	return session, cancel
+}
+
 func (m *transactionManager) withNeverPropagation(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error) error {
-	if txCtx, ok := ctx.(*transactionContext); ok && txCtx.InTransaction() {
+	if txCtx, ok := m.fromContext(ctx); ok && txCtx.InTransaction() {
 		return ErrNeverPropInTransaction
 	}
 
@@ -170,121 +450,156 @@ func (m *transactionManager) withNeverPropagation(ctx context.Context, bizFn fun
 	return bizFn(ctx, db)
 }
 
-func (m *transactionManager) withNestedPropagation(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error) error {
+func (m *transactionManager) withNestedPropagation(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, options *transactionOptions) error {
 	var err error
-	if txCtx, ok := ctx.(*transactionContext); ok && txCtx.InTransaction() {
-		panicked := true
+	if txCtx, ok := m.fromContext(ctx); ok && txCtx.InTransaction() {
 		db := txCtx.TxDB()
 		if !db.DisableNestedTransaction {
-			err = db.SavePoint(fmt.Sprintf("sp%p", bizFn)).Error
+			root := txCtx.Root()
+			mark := root.synchronizationMark()
+			rollbackOnly, rollbackCause := root.rollbackState()
+			savepoint := txCtx.nextSavePoint()
+			err = db.SavePoint(savepoint).Error
+			// kept is the error of bizFn which doesn't roll back, see NoRollbackFor
+			var kept error
+			released := false
 			defer func() {
 				// Make sure to rollback when panic, Block error or Commit error
-				if panicked || err != nil {
-					db.RollbackTo(fmt.Sprintf("sp%p", bizFn))
+				if !released {
+					db.RollbackTo(savepoint)
+					m.observer.OnSavePointRollback(options.info(joinName(txCtx.name, options.name)))
+					root.rollbackSynchronizationsTo(mark)
+					root.restoreRollbackState(rollbackOnly, rollbackCause)
 				}
 			}()
+			if err == nil {
+				err = m.participate(ctx, txCtx, savepoint, bizFn, options)
+				if err != nil && !options.rollbackOn(err) {
+					kept, err = err, nil
+				}
+			}
+			// The savepoint is the boundary of rollback only marked inside of it
+			if marked, _ := root.rollbackState(); err == nil && marked && !rollbackOnly {
+				err = root.unexpectedRollbackError()
+			}
+			if err == nil {
+				err = releaseSavePoint(db, savepoint)
+			}
+			if err == nil {
+				released, err = true, kept
+			}
+		} else {
+			err = m.participate(ctx, txCtx, "", bizFn, options)
 		}
-		if err == nil {
-			err = bizFn(txCtx.Session(), txCtx.TxDB())
-		}
-		panicked = false
 	} else {
-		err = m.withRequiredPropagation(ctx, bizFn)
+		err = m.withRequiredPropagation(ctx, bizFn, options)
 	}
 	return err
 }
 
-func (m *transactionManager) withRequiredPropagation(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error) error {
-	var err error
-	panicked := true
-	if txCtx, ok := ctx.(*transactionContext); ok && txCtx.InTransaction() {
+func (m *transactionManager) withRequiredPropagation(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, options *transactionOptions) error {
+	if txCtx, ok := m.fromContext(ctx); ok && txCtx.InTransaction() {
 		// There is no need to handle errors and panics here, the outer transaction manager will handle it
-		err = bizFn(txCtx.Session(), txCtx.tx)
-	} else {
-		var db *gorm.DB
-		db = m.getPureDB(ctx)
-		if !ok {
-			txCtx = &transactionContext{
-				ctx: ctx,
-				tx:  db.Begin(),
-			}
-		} else {
-			txCtx.tx = db.Begin()
-		}
-		defer func() {
-			if panicked || err != nil {
-				txCtx.Rollback()
-			}
-		}()
-		if err = txCtx.TxError(); err == nil {
-			err = bizFn(txCtx, txCtx.tx)
-		}
-
-		if err == nil {
-			err = txCtx.Commit()
+		if err := m.checkExistingTransaction(txCtx, options); err != nil {
+			return err
 		}
+		return m.participate(ctx, txCtx, "", bizFn, options)
+	} else {
+		return m.withNewTransaction(ctx, bizFn, options)
 	}
-	panicked = false
-	return err
 }
 
-func (m *transactionManager) withRequiresNewPropagation(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error) error {
+func (m *transactionManager) withRequiresNewPropagation(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, options *transactionOptions) error {
+	return m.withNewTransaction(ctx, bizFn, options)
+}
+
+// withNewTransaction begin a new root transaction for bizFn, the transaction bound to ctx (if any) is suspended
+// until the new one completes
+func (m *transactionManager) withNewTransaction(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, options *transactionOptions) (err error) {
 	panicked := true
-	var pureCtx context.Context
-	if txCtx, ok := ctx.(*transactionContext); ok {
-		pureCtx = txCtx.Ctx()
-	} else {
-		pureCtx = ctx
+	start := time.Now()
+	name := callName(ctx, options)
+	txCtx, cancel, err := m.beginTransaction(ctx, name, options)
+	if err != nil {
+		return err
 	}
-	var err error
-	db := m.getPureDB(pureCtx)
-
-	txCtx := &transactionContext{
-		ctx: ctx,
-		tx:  db.Begin(),
+	defer cancel()
+	if m.watchdog != nil {
+		defer m.watchdog.untrack(m.watchdog.track(name, m.key.datasource))
 	}
 	defer func() {
-		if panicked || err != nil {
+		if !txCtx.committed {
 			txCtx.Rollback()
+		} else if !options.readOnly {
+			m.stick(ctx)
+		}
+		// a synchronization panicking after the commit doesn't change the outcome
+		m.observeCompletion(options.info(name), time.Since(start), panicked && !txCtx.committed, txCtx.committed)
+		// recovered here rather than by Transaction to report whether the transaction committed
+		if panicked && m.recoverPanic {
+			if r := recover(); r != nil {
+				err = newPanicError(r, txCtx.committed)
+			}
 		}
 	}()
-	if err = txCtx.TxError(); err == nil {
-		err = bizFn(txCtx, txCtx.tx)
-	}
+	err = bizFn(txCtx, txCtx.tx)
 
-	if err == nil {
-		err = txCtx.Commit()
+	// the work done by bizFn is committed when err doesn't roll back, err is still returned, see NoRollbackFor
+	if !options.rollbackOn(err) {
+		if commitErr := txCtx.Commit(); commitErr != nil {
+			err = commitErr
+		}
 	}
+	err = timeoutError(txCtx.ctx, ctx, err)
 	panicked = false
 	return err
 }
 
-func (m *transactionManager) withSupportsPropagation(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error) error {
-	if txCtx, ok := ctx.(*transactionContext); ok && txCtx.InTransaction() {
+// beginTransaction begin a new root transaction named name, cancel must be called once it completes
+func (m *transactionManager) beginTransaction(ctx context.Context, name string, options *transactionOptions) (*transactionContext, context.CancelFunc, error) {
+	timeoutCtx, cancel := withTimeout(ctx, options.timeout)
+	db := withTransactionLogger(m.getBeginDB(timeoutCtx, options), name)
+
+	txCtx := &transactionContext{
+		key:       m.key,
+		ctx:       timeoutCtx,
+		tx:        db.Begin(options.txOptions()),
+		txOptions: *options.txOptions(),
+		name:      name,
+	}
+	if err := txCtx.TxError(); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	// statements of the transaction run with txCtx as context, so that it can be looked up from them
+	txCtx.tx = txCtx.tx.WithContext(txCtx)
+	return txCtx, cancel, nil
+}
+
+func (m *transactionManager) withSupportsPropagation(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, options *transactionOptions) error {
+	if txCtx, ok := m.fromContext(ctx); ok && txCtx.InTransaction() {
 		// There is no need to handle errors and panics because the outer transaction manager will handle it
-		return bizFn(txCtx.Session(), txCtx.tx)
+		return m.participate(ctx, txCtx, "", bizFn, options)
 	} else {
 		db := m.getPureDB(ctx)
 		return bizFn(ctx, db)
 	}
 }
 
-func (m *transactionManager) withMandatoryPropagation(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error) error {
-	if txCtx, ok := ctx.(*transactionContext); ok && txCtx.InTransaction() {
+func (m *transactionManager) withMandatoryPropagation(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, options *transactionOptions) error {
+	if txCtx, ok := m.fromContext(ctx); ok && txCtx.InTransaction() {
 		// There is no need to handle errors and panics because the outer transaction manager will handle it
-		return bizFn(txCtx.Session(), txCtx.tx)
+		if err := m.checkExistingTransaction(txCtx, options); err != nil {
+			return err
+		}
+		return m.participate(ctx, txCtx, "", bizFn, options)
 	} else {
 		return ErrMandatoryPropWithoutTransaction
 	}
 }
 
 func (m *transactionManager) withNotSupportedPropagation(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error) error {
-	var pureCtx context.Context
-	if txCtx, ok := ctx.(*transactionContext); ok {
-		pureCtx = txCtx.Ctx()
-	} else {
-		pureCtx = ctx
-	}
+	pureCtx := suspend(ctx, m.key)
 	db := m.getPureDB(pureCtx)
 	return bizFn(pureCtx, db)
 }
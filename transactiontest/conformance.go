@@ -0,0 +1,1131 @@
+package transactiontest
+
+import (
+	"context"
+	"errors"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+type user struct {
+	ID         uint
+	Username   string `gorm:"size:64"`
+	CreateTime time.Time
+}
+
+func (user) TableName() string {
+	return "transaction_conformance_user"
+}
+
+var (
+	user1 = &user{Username: "test_user_1", CreateTime: time.Now()}
+	user2 = &user{Username: "test_user_2", CreateTime: time.Now()}
+	user3 = &user{Username: "test_user_3", CreateTime: time.Now()}
+	user4 = &user{Username: "test_user_4", CreateTime: time.Now()}
+)
+
+var (
+	mockErr   = errors.New("mock error")
+	mockPanic = func() { panic("mock panic") }
+	_recover  = func() { recover() }
+)
+
+// conformance is the propagation test matrix of TransactionManager run against a database
+type conformance struct {
+	db        *gorm.DB
+	tm        transaction.TransactionManager
+	lenientTm transaction.TransactionManager
+}
+
+// RunConformanceSuite run the propagation test matrix of TransactionManager against the database of dialector. As
+// SQLite allows a single writer, the outer transactions of the PropagationRequiresNew cases write after the inner ones
+// on SQLite, and before them on the other databases.
+func RunConformanceSuite(t *testing.T, dialector gorm.Dialector) {
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open %v: %v", dialector.Name(), err)
+	}
+	if err := db.AutoMigrate(user{}); err != nil {
+		t.Fatalf("migrate %v: %v", dialector.Name(), err)
+	}
+	s := &conformance{
+		db:        db,
+		tm:        transaction.NewTransactionManager(db),
+		lenientTm: transaction.NewTransactionManager(db, transaction.WithGlobalRollbackOnParticipationFailure(false)),
+	}
+	t.Run("PropagationRequired", s.testPropagationRequired)
+	t.Run("PropagationSupports", s.testPropagationSupports)
+	t.Run("PropagationMandatory", s.testPropagationMandatory)
+	t.Run("PropagationRequiresNew", s.testPropagationRequiresNew)
+	t.Run("PropagationNotSupported", s.testPropagationNotSupported)
+	t.Run("PropagationNested", s.testPropagationNested)
+	t.Run("PropagationNestedSavePoint", s.testPropagationNestedSavePoint)
+	t.Run("PropagationNever", s.testPropagationNever)
+}
+
+func (s *conformance) clearData() {
+	s.db.Delete(user{}, "1=1")
+	for _, u := range []*user{user1, user2, user3, user4} {
+		u.ID = 0
+	}
+}
+
+func (s *conformance) assertExist(u *user, t *testing.T) {
+	t.Helper()
+	var count int64 = 0
+	s.db.Model(&user{}).Where("username = ?", u.Username).Count(&count)
+	if count == 0 {
+		t.Errorf("user %v should exist", u.Username)
+	}
+}
+
+func (s *conformance) assertNotExist(u *user, t *testing.T) {
+	t.Helper()
+	var count int64 = 0
+	s.db.Model(&user{}).Where("username = ?", u.Username).Count(&count)
+	if count > 0 {
+		t.Errorf("user %v should not exist", u.Username)
+	}
+}
+
+func assertErrorsIsEqual(err1, err2 error, t *testing.T) {
+	t.Helper()
+	if err1.Error() != err2.Error() {
+		t.Errorf("errors: [%v], [%v] should equal", err1, err2)
+	}
+}
+
+func (s *conformance) defaultTransactionTest(name string, t *testing.T, testFn func(), checkFn func(t *testing.T)) {
+	t.Run(name, func(t *testing.T) {
+		s.clearData()
+		defer s.clearData()
+		defer func() {
+			if r := recover(); r != nil {
+				t.Logf("test: [%v] catch panic: %v", name, r)
+			}
+		}()
+
+		testFn()
+		checkFn(t)
+	})
+}
+
+func (s *conformance) testPropagationRequired(t *testing.T) {
+
+	s.defaultTransactionTest("test-all-commit",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user3)
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+				return nil
+			}, transaction.PropagationRequired)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertExist(user2, t)
+			s.assertExist(user3, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-one-error-all-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user3)
+					return mockErr
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+				return nil
+			}, transaction.PropagationRequired)
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+			s.assertNotExist(user2, t)
+			s.assertNotExist(user3, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-one-error-all-rollback-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user3)
+					mockPanic()
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+				return nil
+			}, transaction.PropagationRequired)
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+			s.assertNotExist(user2, t)
+			s.assertNotExist(user3, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-one-error-all-rollback-2",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user3)
+
+					if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+						tx.Create(user4)
+						return mockErr
+					}, transaction.PropagationRequired); err != nil {
+						return err
+					}
+
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				return nil
+			}, transaction.PropagationRequired)
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+			s.assertNotExist(user2, t)
+			s.assertNotExist(user3, t)
+			s.assertNotExist(user4, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-one-error-all-rollback-2-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user3)
+
+					if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+						tx.Create(user4)
+						mockPanic()
+						return nil
+					}, transaction.PropagationRequired); err != nil {
+						return err
+					}
+
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				return nil
+			}, transaction.PropagationRequired)
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+			s.assertNotExist(user2, t)
+			s.assertNotExist(user3, t)
+			s.assertNotExist(user4, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-one-error-not-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				if err := s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				//err are ignored, no rollback
+				_ = s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user3)
+					return mockErr
+				}, transaction.PropagationRequired)
+
+				return nil
+			}, transaction.PropagationRequired)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertExist(user2, t)
+			s.assertExist(user3, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-one-error-not-rollback-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				defer _recover()
+				tx.Create(user1)
+
+				if err := s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				//err are ignored, no rollback
+				_ = s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user3)
+					mockPanic()
+					return nil
+				}, transaction.PropagationRequired)
+
+				return nil
+			}, transaction.PropagationRequired)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertExist(user2, t)
+			s.assertExist(user3, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-one-error-not-rollback-2",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				if err := s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				if err := s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user3)
+
+					//err are ignored, no rollback
+					_ = s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+						tx.Create(user4)
+						return mockErr
+					}, transaction.PropagationRequired)
+
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				return nil
+			}, transaction.PropagationRequired)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertExist(user2, t)
+			s.assertExist(user3, t)
+			s.assertExist(user4, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-one-error-not-rollback-2-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				if err := s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				if err := s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					defer _recover()
+					tx.Create(user3)
+
+					//err are ignored, no rollback
+					_ = s.lenientTm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+						tx.Create(user4)
+						mockPanic()
+						return nil
+					}, transaction.PropagationRequired)
+
+					return nil
+				}, transaction.PropagationRequired); err != nil {
+					return err
+				}
+
+				return nil
+			}, transaction.PropagationRequired)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertExist(user2, t)
+			s.assertExist(user3, t)
+			s.assertExist(user4, t)
+		},
+	)
+}
+
+func (s *conformance) testPropagationSupports(t *testing.T) {
+
+	s.defaultTransactionTest("test-supports-propagation-in-transaction",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user1)
+					return nil
+				}, transaction.PropagationSupports)
+
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-supports-propagation-in-transaction-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user1)
+					return nil
+				}, transaction.PropagationSupports)
+
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-supports-propagation-in-transaction-rollback-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user1)
+					return nil
+				}, transaction.PropagationSupports)
+
+				mockPanic()
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-supports-propagation-not-in-transaction-1",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user1)
+					return nil
+				}, transaction.PropagationSupports)
+
+				return mockErr
+			}, transaction.PropagationNever)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-supports-propagation-not-in-transaction-1-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user1)
+					return nil
+				}, transaction.PropagationSupports)
+
+				mockPanic()
+				return nil
+			}, transaction.PropagationNever)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-supports-propagation-not-in-transaction-2",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return mockErr
+			}, transaction.PropagationSupports)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-supports-propagation-not-in-transaction-2-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				mockPanic()
+				return nil
+			}, transaction.PropagationSupports)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+		},
+	)
+}
+
+func (s *conformance) testPropagationMandatory(t *testing.T) {
+
+	s.defaultTransactionTest("test-mandatory-propagation-in-transaction",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user1)
+					return nil
+				}, transaction.PropagationMandatory)
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-mandatory-propagation-in-transaction-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user1)
+					return nil
+				}, transaction.PropagationMandatory)
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-mandatory-propagation-in-transaction-rollback-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user1)
+					return nil
+				}, transaction.PropagationMandatory)
+				mockPanic()
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+		},
+	)
+
+	var err error
+	s.defaultTransactionTest("test-mandatory-propagation-not-in-transaction",
+		t,
+		func() {
+			ctx := context.Background()
+			err = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return nil
+			}, transaction.PropagationMandatory)
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+			assertErrorsIsEqual(err, transaction.ErrMandatoryPropWithoutTransaction, t)
+		},
+	)
+}
+
+func (s *conformance) testPropagationRequiresNew(t *testing.T) {
+	s.defaultTransactionTest("test-user1-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				if !s.singleWriter() {
+					tx.Create(user1)
+				}
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequiresNew)
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user3)
+					return nil
+				}, transaction.PropagationRequiresNew)
+
+				if s.singleWriter() {
+					tx.Create(user1)
+				}
+				return mockErr
+			}, transaction.PropagationRequiresNew)
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+			s.assertExist(user2, t)
+			s.assertExist(user3, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-user1-rollback-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				if !s.singleWriter() {
+					tx.Create(user1)
+				}
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequiresNew)
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user3)
+					return nil
+				}, transaction.PropagationRequiresNew)
+
+				if s.singleWriter() {
+					tx.Create(user1)
+				}
+				mockPanic()
+				return nil
+			}, transaction.PropagationRequiresNew)
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+			s.assertExist(user2, t)
+			s.assertExist(user3, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-user3-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				if !s.singleWriter() {
+					tx.Create(user1)
+				}
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequiresNew)
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user3)
+					return mockErr
+				}, transaction.PropagationRequiresNew)
+
+				if s.singleWriter() {
+					tx.Create(user1)
+				}
+				return nil
+			}, transaction.PropagationRequiresNew)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertExist(user2, t)
+			s.assertNotExist(user3, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-user3-rollback-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				if !s.singleWriter() {
+					tx.Create(user1)
+				}
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationRequiresNew)
+
+				func() {
+					defer _recover()
+					_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+						tx.Create(user3)
+						mockPanic()
+						return nil
+					}, transaction.PropagationRequiresNew)
+				}()
+
+				if s.singleWriter() {
+					tx.Create(user1)
+				}
+				return nil
+			}, transaction.PropagationRequiresNew)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertExist(user2, t)
+			s.assertNotExist(user3, t)
+		},
+	)
+}
+
+// singleWriter report whether the database allows a single writer, the outer transactions of the RequiresNew cases
+// then write after the inner ones instead of holding the lock they wait for
+func (s *conformance) singleWriter() bool {
+	return s.db.Dialector.Name() == "sqlite"
+}
+
+func (s *conformance) testPropagationNotSupported(t *testing.T) {
+
+	s.defaultTransactionTest("test-not-supported-propagation-in-transaction",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user1)
+					return nil
+				}, transaction.PropagationNotSupported)
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-not-supported-propagation-not-in-transaction",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return nil
+			}, transaction.PropagationNotSupported)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+		},
+	)
+}
+
+func (s *conformance) testPropagationNested(t *testing.T) {
+
+	s.defaultTransactionTest("test-outside-commit-nested-inside-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return mockErr
+				}, transaction.PropagationNested)
+
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertNotExist(user2, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-outside-commit-nested-inside-rollback-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				defer _recover()
+				tx.Create(user1)
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					mockPanic()
+					return nil
+				}, transaction.PropagationNested)
+
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertNotExist(user2, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-outside-commit-nested-inside-rollback-2",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+
+					if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+						tx.Create(user3)
+						return mockErr
+					}); err != nil {
+						return err
+					}
+
+					return nil
+				}, transaction.PropagationNested)
+
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertNotExist(user2, t)
+			s.assertNotExist(user3, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-outside-commit-nested-inside-rollback-2-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				defer _recover()
+				tx.Create(user1)
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+
+					if err := s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+						tx.Create(user3)
+						mockPanic()
+						return nil
+					}); err != nil {
+						return err
+					}
+
+					return nil
+				}, transaction.PropagationNested)
+
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertNotExist(user2, t)
+			s.assertNotExist(user3, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-outside-commit-nested-inside-rollback-3",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+
+					_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+						tx.Create(user3)
+						return mockErr
+					}, transaction.PropagationNested)
+
+					return nil
+				}, transaction.PropagationNested)
+
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertExist(user2, t)
+			s.assertNotExist(user3, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-outside-commit-nested-inside-rollback-3-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					defer _recover()
+					tx.Create(user2)
+
+					_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+						tx.Create(user3)
+						mockPanic()
+						return nil
+					}, transaction.PropagationNested)
+
+					return nil
+				}, transaction.PropagationNested)
+
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertExist(user2, t)
+			s.assertNotExist(user3, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-outside-rollback-cause-inside-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationNested)
+
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+			s.assertNotExist(user2, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-outside-rollback-cause-inside-rollback-panic",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationNested)
+
+				mockPanic()
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+			s.assertNotExist(user2, t)
+		},
+	)
+}
+
+func (s *conformance) testPropagationNestedSavePoint(t *testing.T) {
+
+	users := []*user{user1, user2, user3, user4}
+
+	var recursive func(ctx context.Context, depth int, failDepth int) error
+	recursive = func(ctx context.Context, depth int, failDepth int) error {
+		return s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			tx.Create(users[depth])
+			if depth+1 < len(users) {
+				_ = recursive(ctx, depth+1, failDepth)
+			}
+			if depth == failDepth {
+				return mockErr
+			}
+			return nil
+		}, transaction.PropagationNested)
+	}
+
+	s.defaultTransactionTest("test-recursive-nested-inside-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				return recursive(ctx, 0, 2)
+			})
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertExist(user2, t)
+			s.assertNotExist(user3, t)
+			s.assertNotExist(user4, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-recursive-nested-outside-rollback",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				return recursive(ctx, 0, 1)
+			})
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertNotExist(user2, t)
+			s.assertNotExist(user3, t)
+			s.assertNotExist(user4, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-same-function-in-loop",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				for i, user := range users {
+					bizFn := func(ctx context.Context, tx *gorm.DB) error {
+						tx.Create(user)
+						if i%2 == 1 {
+							return mockErr
+						}
+						return nil
+					}
+					_ = s.tm.Transaction(ctx, bizFn, transaction.PropagationNested)
+				}
+				return nil
+			})
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			s.assertNotExist(user2, t)
+			s.assertExist(user3, t)
+			s.assertNotExist(user4, t)
+		},
+	)
+}
+
+func (s *conformance) testPropagationNever(t *testing.T) {
+
+	var err error
+
+	s.defaultTransactionTest("test-never-propagation-in-no-transaction",
+		t,
+		func() {
+			ctx := context.Background()
+			err = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return mockErr
+			}, transaction.PropagationNever)
+		},
+		func(t *testing.T) {
+			s.assertExist(user1, t)
+			assertErrorsIsEqual(err, mockErr, t)
+		},
+	)
+
+	s.defaultTransactionTest("test-never-propagation-in-transaction",
+		t,
+		func() {
+			ctx := context.Background()
+			_ = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+
+				if err = s.tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					tx.Create(user2)
+					return nil
+				}, transaction.PropagationNever); err != nil {
+					return err
+				}
+
+				return mockErr
+			})
+		},
+		func(t *testing.T) {
+			s.assertNotExist(user1, t)
+			s.assertNotExist(user2, t)
+			assertErrorsIsEqual(err, transaction.ErrNeverPropInTransaction, t)
+		},
+	)
+}
@@ -0,0 +1,32 @@
+package transactiontest
+
+import (
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestConformance run the conformance suite against in-process SQLite, and against MySQL and PostgreSQL when
+// MYSQL_DSN and POSTGRES_DSN are set
+func TestConformance(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		RunConformanceSuite(t, sqlite.Open(filepath.Join(t.TempDir(), "conformance.db")))
+	})
+	t.Run("mysql", func(t *testing.T) {
+		dsn := os.Getenv("MYSQL_DSN")
+		if dsn == "" {
+			t.Skip("MYSQL_DSN is not set")
+		}
+		RunConformanceSuite(t, mysql.Open(dsn))
+	})
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv("POSTGRES_DSN")
+		if dsn == "" {
+			t.Skip("POSTGRES_DSN is not set")
+		}
+		RunConformanceSuite(t, postgres.Open(dsn))
+	})
+}
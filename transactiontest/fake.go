@@ -0,0 +1,528 @@
+// Package transactiontest provide test helpers for code using transaction.TransactionManager.
+package transactiontest
+
+import (
+	"context"
+	"fmt"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"gorm.io/gorm"
+	"sync"
+	"testing"
+)
+
+// Outcome is the completion of the transaction or savepoint begun by a call
+type Outcome string
+
+const (
+	OutcomeNone       Outcome = ""            // the call didn't begin a transaction or savepoint
+	OutcomeCommitted  Outcome = "committed"   // the transaction committed or the savepoint was released
+	OutcomeRolledBack Outcome = "rolled back" // the transaction or the savepoint rolled back
+)
+
+// Call is a Transaction call recorded by FakeTransactionManager
+type Call struct {
+	Propagation transaction.Propagation
+	// Depth is the number of Transaction calls enclosing this one
+	Depth int
+	// Began report whether the call began a new transaction
+	Began bool
+	// SavePoint report whether the call created a savepoint
+	SavePoint bool
+	Outcome   Outcome
+	Err       error
+	Panicked  bool
+}
+
+type scopeKey struct{}
+
+// scope is the state of the FakeTransactionManager bound to a context
+type scope struct {
+	tx    *fakeTx
+	depth int
+}
+
+type fakeTx struct {
+	rollbackOnly  bool
+	rollbackCause error
+}
+
+func (tx *fakeTx) markRollbackOnly(cause error) {
+	if !tx.rollbackOnly {
+		tx.rollbackOnly, tx.rollbackCause = true, cause
+	}
+}
+
+func (tx *fakeTx) unexpectedRollbackError() error {
+	if tx.rollbackCause == nil {
+		return transaction.ErrUnexpectedRollback
+	}
+	return fmt.Errorf("%w: %w", transaction.ErrUnexpectedRollback, tx.rollbackCause)
+}
+
+// FakeTransactionManager is an in-memory transaction.TransactionManager implementing the propagations without
+// database. It records every Transaction call and can inject failures on begin, commit and savepoint. The
+// transactions are only visible to the FakeTransactionManager, package functions of transaction like
+// transaction.FromContext don't see them.
+type FakeTransactionManager struct {
+	db *gorm.DB
+
+	mu           sync.Mutex
+	calls        []*Call
+	completed    []*Call
+	beginErrs    []error
+	commitErrs   []error
+	savePointErr []error
+}
+
+// NewFakeTransactionManager create a FakeTransactionManager, GetDB and bizFn get db with the context of the call,
+// db can be nil if the code under test doesn't access the database
+func NewFakeTransactionManager(db *gorm.DB) *FakeTransactionManager {
+	return &FakeTransactionManager{
+		db: db,
+	}
+}
+
+func (m *FakeTransactionManager) GetDB(ctx context.Context) *gorm.DB {
+	if m.db == nil {
+		return nil
+	}
+	return m.db.WithContext(ctx)
+}
+
+func (m *FakeTransactionManager) GetOriginDB() *gorm.DB {
+	return m.db
+}
+
+// FailBegin make the next begin of a transaction fail with err
+func (m *FakeTransactionManager) FailBegin(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.beginErrs = append(m.beginErrs, err)
+}
+
+// FailCommit make the next commit of a transaction fail with err
+func (m *FakeTransactionManager) FailCommit(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commitErrs = append(m.commitErrs, err)
+}
+
+// FailSavePoint make the next savepoint creation fail with err
+func (m *FakeTransactionManager) FailSavePoint(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.savePointErr = append(m.savePointErr, err)
+}
+
+// Calls return the recorded calls in the order they are made
+func (m *FakeTransactionManager) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := make([]Call, 0, len(m.calls))
+	for _, call := range m.calls {
+		calls = append(calls, *call)
+	}
+	return calls
+}
+
+// Reset clear the recorded calls and the injected failures
+func (m *FakeTransactionManager) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls, m.completed, m.beginErrs, m.commitErrs, m.savePointErr = nil, nil, nil, nil, nil
+}
+
+func (m *FakeTransactionManager) Transaction(ctx context.Context, bizFn func(ctx context.Context, tx *gorm.DB) error, opts ...transaction.TransactionOption) (err error) {
+	outer, _ := ctx.Value(scopeKey{}).(*scope)
+	s := &scope{}
+	if outer != nil {
+		s.tx, s.depth = outer.tx, outer.depth+1
+	}
+	call := &Call{Propagation: propagationOf(opts), Depth: s.depth}
+	m.mu.Lock()
+	m.calls = append(m.calls, call)
+	m.mu.Unlock()
+
+	panicked := true
+	defer func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		call.Err, call.Panicked = err, panicked
+		if panicked && (call.Began || call.SavePoint) {
+			call.Outcome = OutcomeRolledBack
+		}
+		if call.Began {
+			m.completed = append(m.completed, call)
+		}
+	}()
+
+	switch call.Propagation {
+	case transaction.PropagationRequired:
+		if s.tx != nil {
+			err = m.participate(ctx, s, bizFn, opts)
+		} else {
+			err = m.begin(ctx, call, s, bizFn, opts)
+		}
+	case transaction.PropagationSupports:
+		if s.tx != nil {
+			err = m.participate(ctx, s, bizFn, opts)
+		} else {
+			err = m.run(ctx, s, bizFn)
+		}
+	case transaction.PropagationMandatory:
+		if s.tx != nil {
+			err = m.participate(ctx, s, bizFn, opts)
+		} else {
+			err = transaction.ErrMandatoryPropWithoutTransaction
+		}
+	case transaction.PropagationRequiresNew:
+		err = m.begin(ctx, call, s, bizFn, opts)
+	case transaction.PropagationNotSupported:
+		s.tx = nil
+		err = m.run(ctx, s, bizFn)
+	case transaction.PropagationNested:
+		if s.tx != nil {
+			err = m.savePoint(ctx, call, s, bizFn, opts)
+		} else {
+			err = m.begin(ctx, call, s, bizFn, opts)
+		}
+	case transaction.PropagationNever:
+		if s.tx != nil {
+			err = transaction.ErrNeverPropInTransaction
+		} else {
+			err = m.run(ctx, s, bizFn)
+		}
+	default:
+		panic("not supported propagation")
+	}
+	panicked = false
+	return err
+}
+
+func (m *FakeTransactionManager) run(ctx context.Context, s *scope, bizFn func(ctx context.Context, tx *gorm.DB) error) error {
+	ctx = context.WithValue(ctx, scopeKey{}, s)
+	return bizFn(ctx, m.GetDB(ctx))
+}
+
+func (m *FakeTransactionManager) begin(ctx context.Context, call *Call, s *scope, bizFn func(ctx context.Context, tx *gorm.DB) error, opts []transaction.TransactionOption) error {
+	if err := m.injected(&m.beginErrs); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	call.Began = true
+	m.mu.Unlock()
+	s.tx = &fakeTx{}
+	err := m.run(ctx, s, bizFn)
+	// an error exempted by NoRollbackFor commits and is still returned
+	committed := !transaction.RollbackOn(err, opts...)
+	if committed && s.tx.rollbackOnly {
+		err, committed = s.tx.unexpectedRollbackError(), false
+	} else if committed {
+		if commitErr := m.injected(&m.commitErrs); commitErr != nil {
+			err, committed = commitErr, false
+		}
+	}
+	m.complete(call, committed)
+	return err
+}
+
+func (m *FakeTransactionManager) participate(ctx context.Context, s *scope, bizFn func(ctx context.Context, tx *gorm.DB) error, opts []transaction.TransactionOption) (err error) {
+	panicked := true
+	defer func() {
+		if panicked {
+			s.tx.markRollbackOnly(fmt.Errorf("participating transaction panicked"))
+		} else if transaction.RollbackOn(err, opts...) {
+			s.tx.markRollbackOnly(err)
+		}
+	}()
+	err = m.run(ctx, s, bizFn)
+	panicked = false
+	return err
+}
+
+func (m *FakeTransactionManager) savePoint(ctx context.Context, call *Call, s *scope, bizFn func(ctx context.Context, tx *gorm.DB) error, opts []transaction.TransactionOption) (err error) {
+	if err := m.injected(&m.savePointErr); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	call.SavePoint = true
+	m.mu.Unlock()
+	rollbackOnly, rollbackCause := s.tx.rollbackOnly, s.tx.rollbackCause
+	released := false
+	defer func() {
+		if !released {
+			s.tx.rollbackOnly, s.tx.rollbackCause = rollbackOnly, rollbackCause
+		}
+	}()
+	err = m.participate(ctx, s, bizFn, opts)
+	released = !transaction.RollbackOn(err, opts...)
+	// the savepoint is the boundary of rollback only marked inside of it
+	if released && s.tx.rollbackOnly && !rollbackOnly {
+		err, released = s.tx.unexpectedRollbackError(), false
+	}
+	m.complete(call, released)
+	return err
+}
+
+func (m *FakeTransactionManager) complete(call *Call, committed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if committed {
+		call.Outcome = OutcomeCommitted
+	} else {
+		call.Outcome = OutcomeRolledBack
+	}
+}
+
+func (m *FakeTransactionManager) injected(errs *[]error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(*errs) == 0 {
+		return nil
+	}
+	err := (*errs)[0]
+	*errs = (*errs)[1:]
+	return err
+}
+
+// Begin start a call like Transaction, the call is recorded when it's started and its outcome when the returned
+// TxHandle completes it. Injected failures apply to Begin, TxHandle.Commit and TxHandle.SavePoint as well.
+func (m *FakeTransactionManager) Begin(ctx context.Context, opts ...transaction.TransactionOption) (context.Context, transaction.TxHandle, error) {
+	outer, _ := ctx.Value(scopeKey{}).(*scope)
+	s := &scope{}
+	if outer != nil {
+		s.tx, s.depth = outer.tx, outer.depth+1
+	}
+	call := &Call{Propagation: propagationOf(opts), Depth: s.depth}
+	m.mu.Lock()
+	m.calls = append(m.calls, call)
+	m.mu.Unlock()
+	h := &fakeTxHandle{m: m, call: call, s: s}
+
+	var err error
+	switch call.Propagation {
+	case transaction.PropagationRequired:
+		if s.tx == nil {
+			err = h.begin()
+		}
+	case transaction.PropagationSupports:
+	case transaction.PropagationMandatory:
+		if s.tx == nil {
+			err = transaction.ErrMandatoryPropWithoutTransaction
+		}
+	case transaction.PropagationRequiresNew:
+		err = h.begin()
+	case transaction.PropagationNotSupported:
+		s.tx = nil
+	case transaction.PropagationNested:
+		if s.tx != nil {
+			err = h.savePoint()
+		} else {
+			err = h.begin()
+		}
+	case transaction.PropagationNever:
+		if s.tx != nil {
+			err = transaction.ErrNeverPropInTransaction
+		}
+	default:
+		panic("not supported propagation")
+	}
+	if err != nil {
+		m.mu.Lock()
+		call.Err = err
+		m.mu.Unlock()
+		return ctx, nil, err
+	}
+	return context.WithValue(ctx, scopeKey{}, s), h, nil
+}
+
+type fakeTxHandle struct {
+	m      *FakeTransactionManager
+	call   *Call
+	s      *scope
+	status transaction.TxStatus
+	// the state of the transaction before the savepoint created by the call
+	rollbackOnly  bool
+	rollbackCause error
+	savePoints    map[string]bool
+}
+
+func (h *fakeTxHandle) begin() error {
+	if err := h.m.injected(&h.m.beginErrs); err != nil {
+		return err
+	}
+	h.m.mu.Lock()
+	h.call.Began = true
+	h.m.mu.Unlock()
+	h.s.tx = &fakeTx{}
+	return nil
+}
+
+func (h *fakeTxHandle) savePoint() error {
+	if err := h.m.injected(&h.m.savePointErr); err != nil {
+		return err
+	}
+	h.m.mu.Lock()
+	h.call.SavePoint = true
+	h.m.mu.Unlock()
+	h.rollbackOnly, h.rollbackCause = h.s.tx.rollbackOnly, h.s.tx.rollbackCause
+	return nil
+}
+
+func (h *fakeTxHandle) Commit() error {
+	if h.status != transaction.TxStatusActive {
+		return transaction.ErrTransactionCompleted
+	}
+	var err error
+	switch {
+	case h.call.Began:
+		if h.s.tx.rollbackOnly {
+			err = h.s.tx.unexpectedRollbackError()
+		} else {
+			err = h.m.injected(&h.m.commitErrs)
+		}
+	case h.call.SavePoint:
+		// the savepoint is the boundary of rollback only marked inside of it
+		if h.s.tx.rollbackOnly && !h.rollbackOnly {
+			err = h.s.tx.unexpectedRollbackError()
+			h.s.tx.rollbackOnly, h.s.tx.rollbackCause = h.rollbackOnly, h.rollbackCause
+		}
+	}
+	if err != nil {
+		h.complete(transaction.TxStatusRolledBack, err)
+	} else {
+		h.complete(transaction.TxStatusCommitted, nil)
+	}
+	return err
+}
+
+func (h *fakeTxHandle) Rollback() error {
+	if h.status != transaction.TxStatusActive {
+		return transaction.ErrTransactionCompleted
+	}
+	switch {
+	case h.call.Began:
+	case h.call.SavePoint:
+		h.s.tx.rollbackOnly, h.s.tx.rollbackCause = h.rollbackOnly, h.rollbackCause
+	case h.s.tx != nil:
+		h.s.tx.markRollbackOnly(nil)
+	}
+	h.complete(transaction.TxStatusRolledBack, nil)
+	return nil
+}
+
+// complete record the outcome of the call, err is the error returned by Commit
+func (h *fakeTxHandle) complete(status transaction.TxStatus, err error) {
+	h.status = status
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	h.call.Err = err
+	if h.call.Began || h.call.SavePoint {
+		if status == transaction.TxStatusCommitted {
+			h.call.Outcome = OutcomeCommitted
+		} else {
+			h.call.Outcome = OutcomeRolledBack
+		}
+	}
+	if h.call.Began {
+		h.m.completed = append(h.m.completed, h.call)
+	}
+}
+
+func (h *fakeTxHandle) SavePoint(name string) error {
+	if h.status != transaction.TxStatusActive {
+		return transaction.ErrTransactionCompleted
+	}
+	if h.s.tx == nil {
+		return transaction.ErrSavePointWithoutTransaction
+	}
+	if err := h.m.injected(&h.m.savePointErr); err != nil {
+		return err
+	}
+	if h.savePoints == nil {
+		h.savePoints = map[string]bool{}
+	}
+	h.savePoints[name] = true
+	return nil
+}
+
+func (h *fakeTxHandle) RollbackTo(name string) error {
+	if h.status != transaction.TxStatusActive {
+		return transaction.ErrTransactionCompleted
+	}
+	if h.s.tx == nil {
+		return transaction.ErrSavePointWithoutTransaction
+	}
+	if !h.savePoints[name] {
+		return fmt.Errorf("savepoint %v does not exist", name)
+	}
+	return nil
+}
+
+func (h *fakeTxHandle) Status() transaction.TxStatus {
+	return h.status
+}
+
+func propagationOf(opts []transaction.TransactionOption) transaction.Propagation {
+	propagation := transaction.PropagationRequired
+	for _, opt := range opts {
+		if p, ok := opt.(transaction.Propagation); ok {
+			propagation = p
+		}
+	}
+	return propagation
+}
+
+// roots return the calls which began a transaction in the order the transactions complete
+func (m *FakeTransactionManager) roots() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	roots := make([]Call, 0, len(m.completed))
+	for _, call := range m.completed {
+		roots = append(roots, *call)
+	}
+	return roots
+}
+
+// AssertCommitted assert that a transaction has been begun and the last completed one committed
+func (m *FakeTransactionManager) AssertCommitted(t testing.TB) {
+	t.Helper()
+	roots := m.roots()
+	if len(roots) == 0 {
+		t.Errorf("no transaction has been begun")
+	} else if last := roots[len(roots)-1]; last.Outcome != OutcomeCommitted {
+		t.Errorf("transaction should be committed, but %v: %v", last.Outcome, last.Err)
+	}
+}
+
+// AssertRolledBack assert that a transaction has been begun and the last completed one rolled back
+func (m *FakeTransactionManager) AssertRolledBack(t testing.TB) {
+	t.Helper()
+	roots := m.roots()
+	if len(roots) == 0 {
+		t.Errorf("no transaction has been begun")
+	} else if last := roots[len(roots)-1]; last.Outcome != OutcomeRolledBack {
+		t.Errorf("transaction should be rolled back, but %v", last.Outcome)
+	}
+}
+
+// AssertNoTransaction assert that no transaction has been begun
+func (m *FakeTransactionManager) AssertNoTransaction(t testing.TB) {
+	t.Helper()
+	if roots := m.roots(); len(roots) > 0 {
+		t.Errorf("no transaction should be begun, but %v", len(roots))
+	}
+}
+
+// AssertPropagations assert the propagations of the recorded calls in order
+func (m *FakeTransactionManager) AssertPropagations(t testing.TB, propagations ...transaction.Propagation) {
+	t.Helper()
+	calls := m.Calls()
+	actual := make([]transaction.Propagation, 0, len(calls))
+	for _, call := range calls {
+		actual = append(actual, call.Propagation)
+	}
+	if fmt.Sprint(actual) != fmt.Sprint(propagations) {
+		t.Errorf("propagations should be %v, but %v", propagations, actual)
+	}
+}
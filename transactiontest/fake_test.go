@@ -0,0 +1,161 @@
+package transactiontest
+
+import (
+	"context"
+	"errors"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestFakeTransactionManager(t *testing.T) {
+	tm := NewFakeTransactionManager(nil)
+	ctx := context.Background()
+
+	err := tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			return mockErr
+		}, transaction.PropagationNested)
+		_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			return tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				return nil
+			}, transaction.PropagationMandatory)
+		}, transaction.PropagationRequiresNew)
+		return tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			return nil
+		}, transaction.PropagationNever)
+	})
+	if !errors.Is(err, transaction.ErrNeverPropInTransaction) {
+		t.Errorf("error should be %v, but %v", transaction.ErrNeverPropInTransaction, err)
+	}
+	tm.AssertPropagations(t, transaction.PropagationRequired, transaction.PropagationNested,
+		transaction.PropagationRequiresNew, transaction.PropagationMandatory, transaction.PropagationNever)
+	tm.AssertRolledBack(t)
+
+	calls := tm.Calls()
+	expected := []Call{
+		{Propagation: transaction.PropagationRequired, Depth: 0, Began: true, Outcome: OutcomeRolledBack, Err: err},
+		{Propagation: transaction.PropagationNested, Depth: 1, SavePoint: true, Outcome: OutcomeRolledBack, Err: mockErr},
+		{Propagation: transaction.PropagationRequiresNew, Depth: 1, Began: true, Outcome: OutcomeCommitted},
+		{Propagation: transaction.PropagationMandatory, Depth: 2},
+		{Propagation: transaction.PropagationNever, Depth: 1, Err: transaction.ErrNeverPropInTransaction},
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("call %v should be %+v, but %+v", i, expected[i], calls[i])
+		}
+	}
+}
+
+func TestFakeTransactionManager_RollbackOnly(t *testing.T) {
+	tm := NewFakeTransactionManager(nil)
+	err := tm.Transaction(context.Background(), func(ctx context.Context, tx *gorm.DB) error {
+		_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			return mockErr
+		})
+		return nil
+	})
+	if !errors.Is(err, transaction.ErrUnexpectedRollback) || !errors.Is(err, mockErr) {
+		t.Errorf("error should be %v caused by %v, but %v", transaction.ErrUnexpectedRollback, mockErr, err)
+	}
+	tm.AssertRolledBack(t)
+}
+
+func TestFakeTransactionManager_InjectedFailures(t *testing.T) {
+	tm := NewFakeTransactionManager(nil)
+	ctx := context.Background()
+	fn := func(ctx context.Context, tx *gorm.DB) error { return nil }
+
+	tm.FailBegin(mockErr)
+	if err := tm.Transaction(ctx, fn); err != mockErr {
+		t.Errorf("begin should fail with %v, but %v", mockErr, err)
+	}
+	tm.AssertNoTransaction(t)
+
+	tm.FailCommit(mockErr)
+	if err := tm.Transaction(ctx, fn); err != mockErr {
+		t.Errorf("commit should fail with %v, but %v", mockErr, err)
+	}
+	tm.AssertRolledBack(t)
+
+	tm.FailSavePoint(mockErr)
+	err := tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		if err := tm.Transaction(ctx, fn, transaction.PropagationNested); err != mockErr {
+			t.Errorf("savepoint should fail with %v, but %v", mockErr, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("error should be nil, but %v", err)
+	}
+	tm.AssertCommitted(t)
+
+	func() {
+		defer func() { recover() }()
+		_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			panic("mock panic")
+		})
+	}()
+	tm.AssertRolledBack(t)
+	if calls := tm.Calls(); !calls[len(calls)-1].Panicked {
+		t.Errorf("call should be recorded as panicked")
+	}
+
+	tm.Reset()
+	if calls := tm.Calls(); len(calls) != 0 {
+		t.Errorf("calls should be cleared, but %v", calls)
+	}
+}
+
+func TestFakeTransactionManager_Begin(t *testing.T) {
+	tm := NewFakeTransactionManager(nil)
+
+	ctx, h, err := tm.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nestedCtx, nested, _ := tm.Begin(ctx, transaction.PropagationNested)
+	_, inner, _ := tm.Begin(nestedCtx, transaction.PropagationMandatory)
+	// the rollback only marked by inner is bounded by the savepoint
+	_ = inner.Rollback()
+	if err := nested.Commit(); !errors.Is(err, transaction.ErrUnexpectedRollback) {
+		t.Errorf("error should be %v, but %v", transaction.ErrUnexpectedRollback, err)
+	}
+	tm.FailCommit(mockErr)
+	if err := h.Commit(); err != mockErr {
+		t.Errorf("commit should fail with %v, but %v", mockErr, err)
+	}
+	if err := h.Rollback(); err != transaction.ErrTransactionCompleted {
+		t.Errorf("error should be %v, but %v", transaction.ErrTransactionCompleted, err)
+	}
+	tm.AssertPropagations(t, transaction.PropagationRequired, transaction.PropagationNested, transaction.PropagationMandatory)
+	tm.AssertRolledBack(t)
+
+	calls := tm.Calls()
+	if calls[1].Outcome != OutcomeRolledBack || calls[0].Err != mockErr {
+		t.Errorf("savepoint and transaction should be rolled back, but %+v", calls)
+	}
+}
+
+func TestFakeTransactionManager_NoRollbackFor(t *testing.T) {
+	tm := NewFakeTransactionManager(nil)
+	noRollback := transaction.NoRollbackFor(transaction.ErrorIs(mockErr))
+
+	err := tm.Transaction(context.Background(), func(ctx context.Context, tx *gorm.DB) error {
+		// neither marks the transaction rollback only
+		_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			return mockErr
+		}, noRollback)
+		_ = tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			return mockErr
+		}, transaction.PropagationNested, noRollback)
+		return mockErr
+	}, noRollback)
+	if err != mockErr {
+		t.Errorf("error should be %v, but %v", mockErr, err)
+	}
+	tm.AssertCommitted(t)
+	if calls := tm.Calls(); calls[2].Outcome != OutcomeCommitted {
+		t.Errorf("savepoint should be released, but %v", calls[2].Outcome)
+	}
+}
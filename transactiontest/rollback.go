@@ -0,0 +1,44 @@
+package transactiontest
+
+import (
+	"context"
+	"errors"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"gorm.io/gorm"
+	"testing"
+)
+
+var errRollback = errors.New("transactiontest: roll back the test transaction")
+
+// WithRollback run fn in a root transaction of tm which is rolled back in t.Cleanup, so that the data written by a
+// test never leaks to the others. The Transaction calls inside fn with PropagationRequired, PropagationRequiresNew
+// or PropagationNested participate in it with savepoints instead of committing, see transaction.WithSavePointScope.
+// Calls leaving the transaction, e.g. PropagationNotSupported or a manager of another datasource, still commit.
+func WithRollback(t testing.TB, tm transaction.TransactionManager, fn func(ctx context.Context)) {
+	t.Helper()
+	began := make(chan context.Context)
+	done := make(chan struct{})
+	result := make(chan error, 1)
+	// the transaction stays open until t.Cleanup, so bizFn waits for it in another goroutine
+	go func() {
+		result <- tm.Transaction(transaction.WithSavePointScope(context.Background()), func(ctx context.Context, tx *gorm.DB) error {
+			began <- ctx
+			<-done
+			return errRollback
+		}, transaction.PropagationRequiresNew)
+	}()
+
+	var ctx context.Context
+	select {
+	case ctx = <-began:
+	case err := <-result:
+		t.Fatalf("transactiontest: begin the test transaction: %v", err)
+	}
+	t.Cleanup(func() {
+		close(done)
+		if err := <-result; !errors.Is(err, errRollback) {
+			t.Errorf("transactiontest: roll back the test transaction: %v", err)
+		}
+	})
+	fn(ctx)
+}
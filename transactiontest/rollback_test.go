@@ -0,0 +1,59 @@
+package transactiontest
+
+import (
+	"context"
+	"errors"
+	transaction "github.com/storm-blue/gorm-transaction"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestWithRollback(t *testing.T) {
+	db := OpenSQLite(t, user{})
+	tm := transaction.NewTransactionManager(db)
+	s := &conformance{db: db, tm: tm}
+	s.clearData()
+	defer s.clearData()
+
+	count := func(db *gorm.DB) int64 {
+		var n int64
+		db.Model(user{}).Count(&n)
+		return n
+	}
+
+	t.Run("writes", func(t *testing.T) {
+		WithRollback(t, tm, func(ctx context.Context) {
+			if _, ok := transaction.FromContext(ctx); !ok {
+				t.Fatal("ctx should be in transaction")
+			}
+			for _, p := range []transaction.Propagation{
+				transaction.PropagationRequired, transaction.PropagationRequiresNew, transaction.PropagationNested,
+			} {
+				if err := tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+					txCtx, _ := transaction.FromContext(ctx)
+					if txCtx.SavePoint() == "" {
+						t.Errorf("%v should run in a savepoint", p)
+					}
+					return tx.Create(&user{Username: p.String()}).Error
+				}, p); err != nil {
+					t.Errorf("%v: %v", p, err)
+				}
+			}
+			// a failed call only rolls back its savepoint
+			err := tm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				tx.Create(user1)
+				return mockErr
+			}, transaction.PropagationRequiresNew)
+			if !errors.Is(err, mockErr) {
+				t.Errorf("error should be %v, but %v", mockErr, err)
+			}
+			if n := count(tm.GetDB(ctx)); n != 3 {
+				t.Errorf("%v users should be visible in the test transaction, but %v", 3, n)
+			}
+		})
+	})
+
+	if n := count(db); n != 0 {
+		t.Errorf("test transaction should be rolled back, but %v users are left", n)
+	}
+}
@@ -0,0 +1,23 @@
+package transactiontest
+
+import (
+	"github.com/storm-blue/gorm-transaction/internal/sqlitetest"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// OpenSQLite open a SQLite database in a temporary directory of tb and migrate models, the database is closed and
+// removed when tb finishes
+func OpenSQLite(tb testing.TB, models ...interface{}) *gorm.DB {
+	tb.Helper()
+	db, err := sqlitetest.Open(tb.TempDir(), models...)
+	if err != nil {
+		tb.Fatalf("transactiontest: open SQLite: %v", err)
+	}
+	tb.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
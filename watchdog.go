@@ -0,0 +1,158 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ActiveTransaction describe a root transaction tracked by Watchdog
+type ActiveTransaction struct {
+	ID         uint64
+	Name       string
+	DataSource string
+	Start      time.Time
+	// Stack is the stack of the caller beginning the transaction
+	Stack string
+}
+
+// Watchdog track the open root transactions of the TransactionManagers it's installed to, see WithWatchdog, and
+// report the transactions running longer than a threshold
+type Watchdog struct {
+	threshold time.Duration
+	report    func(tx ActiveTransaction)
+
+	mu       sync.Mutex
+	seq      uint64
+	active   map[uint64]ActiveTransaction
+	reported map[uint64]bool
+}
+
+// NewWatchdog create a Watchdog invoking report once for each transaction open longer than threshold, Check or
+// Run must be called for the reporting. It panics when threshold isn't positive or report is nil.
+func NewWatchdog(threshold time.Duration, report func(tx ActiveTransaction)) *Watchdog {
+	if threshold <= 0 {
+		panic("watchdog threshold must be positive")
+	}
+	if report == nil {
+		panic("watchdog needs a report function")
+	}
+	return &Watchdog{
+		threshold: threshold,
+		report:    report,
+		active:    make(map[uint64]ActiveTransaction),
+		reported:  make(map[uint64]bool),
+	}
+}
+
+// Snapshot return the open transactions ordered by start time
+func (w *Watchdog) Snapshot() []ActiveTransaction {
+	w.mu.Lock()
+	snapshot := make([]ActiveTransaction, 0, len(w.active))
+	for _, tx := range w.active {
+		snapshot = append(snapshot, tx)
+	}
+	w.mu.Unlock()
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].ID < snapshot[j].ID
+	})
+	return snapshot
+}
+
+// Check report the transactions exceeding the threshold which are not reported yet
+func (w *Watchdog) Check() {
+	var exceeded []ActiveTransaction
+	now := time.Now()
+	w.mu.Lock()
+	for id, tx := range w.active {
+		if !w.reported[id] && now.Sub(tx.Start) > w.threshold {
+			w.reported[id] = true
+			exceeded = append(exceeded, tx)
+		}
+	}
+	w.mu.Unlock()
+	sort.Slice(exceeded, func(i, j int) bool {
+		return exceeded[i].ID < exceeded[j].ID
+	})
+	for _, tx := range exceeded {
+		w.report(tx)
+	}
+}
+
+// Run call Check every half threshold until ctx is done
+func (w *Watchdog) Run(ctx context.Context) error {
+	ticker := time.NewTicker(max(w.threshold/2, time.Nanosecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			w.Check()
+		}
+	}
+}
+
+func (w *Watchdog) track(name string, datasource string) uint64 {
+	tx := ActiveTransaction{
+		Name:       name,
+		DataSource: datasource,
+		Start:      time.Now(),
+		Stack:      callerStack(),
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.seq++
+	tx.ID = w.seq
+	w.active[tx.ID] = tx
+	return tx.ID
+}
+
+func (w *Watchdog) untrack(id uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.active, id)
+	delete(w.reported, id)
+}
+
+// internalFrames prefix the functions of the TransactionManager internals, they are skipped from the caller stack
+var internalFrames = []string{
+	reflect.TypeOf(transactionManager{}).PkgPath() + ".(*transactionManager).",
+	reflect.TypeOf(transactionManager{}).PkgPath() + ".(*RetryPolicy).",
+	reflect.TypeOf(transactionManager{}).PkgPath() + ".(*Watchdog).",
+	reflect.TypeOf(transactionManager{}).PkgPath() + ".callerStack",
+}
+
+// callerStack format the stack of the caller beginning a transaction
+func callerStack() string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var b strings.Builder
+	internal := true
+	for {
+		frame, more := frames.Next()
+		internal = internal && isInternalFrame(frame.Function)
+		if !internal {
+			_, _ = fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+func isInternalFrame(function string) bool {
+	for _, prefix := range internalFrames {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
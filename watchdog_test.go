@@ -0,0 +1,74 @@
+package transaction
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	var reported []ActiveTransaction
+	watchdog := NewWatchdog(50*time.Millisecond, func(tx ActiveTransaction) {
+		reported = append(reported, tx)
+	})
+	wtm := NewTransactionManager(db, WithWatchdog(watchdog), WithDataSource("orders"))
+	ctx := context.Background()
+
+	_ = wtm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		return wtm.Transaction(ctx, func(ctx context.Context, tx *gorm.DB) error {
+			snapshot := watchdog.Snapshot()
+			if len(snapshot) != 1 {
+				t.Errorf("only the root transaction should be tracked, but %v", snapshot)
+				return nil
+			}
+			active := snapshot[0]
+			if active.Name != "PlaceOrder" || active.DataSource != "orders" {
+				t.Errorf("transaction should be tracked with its name and datasource, but %+v", active)
+			}
+			if !strings.HasPrefix(active.Stack, "github.com/storm-blue/gorm-transaction.TestWatchdog") {
+				t.Errorf("stack should start with the caller, but %v", active.Stack)
+			}
+
+			watchdog.Check()
+			if len(reported) != 0 {
+				t.Errorf("transaction should not be reported before the threshold")
+			}
+			time.Sleep(60 * time.Millisecond)
+			watchdog.Check()
+			watchdog.Check()
+			if len(reported) != 1 || reported[0].ID != active.ID {
+				t.Errorf("transaction should be reported once, but %v", reported)
+			}
+			return nil
+		}, WithName("ReserveStock"))
+	}, WithName("PlaceOrder"))
+
+	if snapshot := watchdog.Snapshot(); len(snapshot) != 0 {
+		t.Errorf("completed transaction should not be tracked, but %v", snapshot)
+	}
+}
+
+func TestNewWatchdog_Invalid(t *testing.T) {
+	report := func(tx ActiveTransaction) {}
+	for name, newWatchdog := range map[string]func(){
+		"zero-threshold":     func() { NewWatchdog(0, report) },
+		"negative-threshold": func() { NewWatchdog(-time.Second, report) },
+		"nil-report":         func() { NewWatchdog(time.Second, nil) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("invalid watchdog should be rejected")
+				}
+			}()
+			newWatchdog()
+		})
+	}
+
+	// the smallest threshold still gives Run a valid interval
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_ = NewWatchdog(time.Nanosecond, report).Run(ctx)
+}